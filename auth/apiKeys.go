@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to spot.
+const apiKeyPrefix = "bk_"
+
+// ApiKeyVerifier returns the principal of an API key, failing unless the key
+// is known, unexpired and unrevoked.
+type ApiKeyVerifier interface {
+	VerifyApiKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// GenerateApiKey returns a new API key along with its id and secret. The key
+// is the id and secret joined after apiKeyPrefix.
+func GenerateApiKey() (key string, id string, secret string, err error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+	id = hex.EncodeToString(idBytes)
+	secret = base64.RawURLEncoding.EncodeToString(secretBytes)
+	return apiKeyPrefix + id + "_" + secret, id, secret, nil
+}
+
+// ParseApiKey splits a key made by GenerateApiKey into its id and secret.
+func ParseApiKey(key string) (id string, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, apiKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+	id, secret, found = strings.Cut(rest, "_")
+	if !found || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// NewSalt returns a random salt for HashApiKeySecret.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// HashApiKeySecret returns the salted hash of the secret of an API key. The
+// secrets are random, so a fast hash is as good as a slow one.
+func HashApiKeySecret(salt []byte, secret string) []byte {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(secret))
+	return hash.Sum(nil)
+}
+
+// ApiKeySecretMatches reports in constant time whether the secret hashes to hash.
+func ApiKeySecretMatches(salt []byte, hash []byte, secret string) bool {
+	return subtle.ConstantTimeCompare(HashApiKeySecret(salt, secret), hash) == 1
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	e "cloudbees/errors"
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationMetadataKey is the request metadata holding the bearer token.
+const authorizationMetadataKey = "authorization"
+
+// apiKeyMetadataKey is the request metadata holding an API key.
+const apiKeyMetadataKey = "x-api-key"
+
+// Authenticator rejects calls that don't authenticate, and passes the
+// principal of the others to their handlers.
+type Authenticator struct {
+	jwt     *JWTVerifier
+	apiKeys ApiKeyVerifier
+	// anonymous holds the full names of the methods callers may call
+	// without authenticating.
+	anonymous map[string]bool
+}
+
+// NewAuthenticator returns an Authenticator verifying bearer tokens with jwt
+// and API keys with apiKeys, if not nil, and letting unauthenticated callers
+// call the anonymous methods, given by full name such as
+// "/posts.BlogService/GetPost".
+func NewAuthenticator(jwt *JWTVerifier, apiKeys ApiKeyVerifier, anonymous ...string) *Authenticator {
+	a := &Authenticator{jwt: jwt, apiKeys: apiKeys, anonymous: make(map[string]bool, len(anonymous))}
+	for _, method := range anonymous {
+		a.anonymous[method] = true
+	}
+	return a
+}
+
+// bearerToken returns the bearer token of the call, or "" if it has none.
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authorizationMetadataKey) {
+		scheme, token, found := strings.Cut(value, " ")
+		if found && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// apiKey returns the API key of the call, or "" if it has none.
+func apiKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(apiKeyMetadataKey); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// authenticate returns ctx with the principal of the call, authenticated by
+// its bearer token or else its API key. Calls of anonymous methods without
+// credentials get ctx back unchanged; calls with invalid credentials are
+// rejected even then.
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if token := bearerToken(ctx); token != "" {
+		principal, err := a.jwt.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, e.InvalidTokenError.Error())
+		}
+		return NewContext(ctx, principal), nil
+	}
+	if key := apiKey(ctx); key != "" && a.apiKeys != nil {
+		principal, err := a.apiKeys.VerifyApiKey(ctx, key)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, e.InvalidApiKeyError.Error())
+		}
+		return NewContext(ctx, principal), nil
+	}
+	if a.anonymous[method] {
+		return ctx, nil
+	}
+	return nil, status.Error(codes.Unauthenticated, e.AuthenticationMissingError.Error())
+}
+
+// UnaryInterceptor authenticates unary calls.
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// clockSkew is how far the clocks of token issuers may be off.
+const clockSkew = 30 * time.Second
+
+// jwtClaims are the claims of the bearer tokens. Roles holds the names of
+// the principal's roles.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// JWTVerifier checks bearer tokens signed with HS256 by a shared secret, or
+// with RS256 or ES256 by a key of a JWKS.
+type JWTVerifier struct {
+	secret  []byte
+	keys    []publicKey
+	methods []string
+	options []jwt.ParserOption
+}
+
+// NewJWTVerifier reads the HS256 secret from secretFile and the public keys
+// from jwksFile; either may be empty, not both. Tokens must name issuer and
+// audience unless they are empty.
+func NewJWTVerifier(secretFile string, jwksFile string, issuer string, audience string) (*JWTVerifier, error) {
+	verifier := &JWTVerifier{}
+	if secretFile != "" {
+		secret, err := os.ReadFile(secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read JWT secret: %w", err)
+		}
+		verifier.secret = bytes.TrimSpace(secret)
+		if len(verifier.secret) < 32 {
+			return nil, errors.New("JWT secret is too short, use at least 32 bytes")
+		}
+		verifier.methods = append(verifier.methods, jwt.SigningMethodHS256.Alg())
+	}
+	if jwksFile != "" {
+		keys, err := loadJWKS(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.keys = keys
+		for _, alg := range []string{"RS256", "ES256"} {
+			for _, key := range keys {
+				if key.alg == alg {
+					verifier.methods = append(verifier.methods, alg)
+					break
+				}
+			}
+		}
+	}
+	if len(verifier.methods) == 0 {
+		return nil, errors.New("JWT verification needs a secret or a JWKS")
+	}
+
+	verifier.options = []jwt.ParserOption{
+		jwt.WithValidMethods(verifier.methods),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		verifier.options = append(verifier.options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		verifier.options = append(verifier.options, jwt.WithAudience(audience))
+	}
+	return verifier, nil
+}
+
+// Verify returns the principal the token was issued to, with the roles of its
+// roles claim, failing unless the token is validly signed, unexpired and has
+// a subject.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims := &jwtClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.key, v.options...); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{Subject: claims.Subject, Roles: ParseRoles(claims.Roles)}, nil
+}
+
+// key returns the key verifying the token: the secret for HS256 tokens, and
+// for others the JWKS key with the token's key id, or the only key of the
+// token's algorithm when the token has none.
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return v.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	var found *publicKey
+	for i, key := range v.keys {
+		if key.alg != alg {
+			continue
+		}
+		if kid != "" && key.kid == kid {
+			return key.key, nil
+		}
+		if kid == "" {
+			if found != nil {
+				return nil, errors.New("token has no key id and the JWKS has several keys")
+			}
+			found = &v.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no %s key with id %q", alg, kid)
+	}
+	return found.key, nil
+}
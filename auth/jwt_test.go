@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func encode(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// writeJWKS writes a JWKS of the public keys of rsaKey and ecKey.
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+	jwks, err := json.Marshal(map[string][]jsonWebKey{"keys": {
+		{Kty: "RSA", Kid: "rsa-1", Alg: "RS256", Use: "sig", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kty: "EC", Kid: "ec-1", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+		{Kty: "oct", Kid: "ignored"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to marshal JWKS: %v", err)
+	}
+	return writeTestFile(t, "jwks.json", jwks)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://issuer.example",
+		Audience:  jwt.ClaimStrings{"blog"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verifier, err := NewJWTVerifier(writeTestFile(t, "secret", []byte(testSecret+"\n")), writeJWKS(t, rsaKey, ecKey), "https://issuer.example", "blog")
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	expired := validClaims("alice")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongAudience := validClaims("alice")
+	wrongAudience.Audience = jwt.ClaimStrings{"other"}
+	noExpiry := validClaims("alice")
+	noExpiry.ExpiresAt = nil
+	tests := []struct {
+		name    string
+		token   string
+		subject string
+	}{
+		{"HS256", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("alice")), "alice"},
+		{"RS256", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims("bob")), "bob"},
+		{"ES256 without key id", sign(t, jwt.SigningMethodES256, "", ecKey, validClaims("carol")), "carol"},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, "", []byte("another secret of at least 32 bytes"), validClaims("alice")), ""},
+		{"unknown key", sign(t, jwt.SigningMethodES256, "ec-1", otherKey, validClaims("alice")), ""},
+		{"unknown key id", sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims("alice")), ""},
+		{"unaccepted algorithm", sign(t, jwt.SigningMethodHS512, "", []byte(testSecret), validClaims("alice")), ""},
+		{"unsigned", sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims("alice")), ""},
+		{"expired", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), expired), ""},
+		{"without expiry", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), noExpiry), ""},
+		{"wrong audience", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), wrongAudience), ""},
+		{"without subject", sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("")), ""},
+		{"garbage", "not.a.token", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := verifier.Verify(test.token)
+			if test.subject == "" {
+				if err == nil {
+					t.Fatalf("expected the token to be rejected, got %+v", principal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the token to be accepted, got %v", err)
+			}
+			if principal.Subject != test.subject {
+				t.Fatalf("expected subject %q, got %q", test.subject, principal.Subject)
+			}
+		})
+	}
+}
+
+func TestNewJWTVerifierRejectsWeakSecrets(t *testing.T) {
+	if _, err := NewJWTVerifier(writeTestFile(t, "secret", []byte("short")), "", "", ""); err == nil {
+		t.Fatalf("expected a short secret to be rejected")
+	}
+	if _, err := NewJWTVerifier("", writeTestFile(t, "jwks.json", []byte(`{"keys": []}`)), "", ""); err == nil {
+		t.Fatalf("expected a JWKS without keys to be rejected")
+	}
+}
+
+func TestAuthenticatorUnaryInterceptor(t *testing.T) {
+	verifier, err := NewJWTVerifier(writeTestFile(t, "secret", []byte(testSecret)), "", "", "")
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	authenticator := NewAuthenticator(verifier, nil, "/posts.BlogService/GetPost")
+	call := func(method string, authorization string) (*Principal, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		var principal *Principal
+		_, err := authenticator.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = FromContext(ctx)
+			return nil, nil
+		})
+		return principal, err
+	}
+	token := sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("alice"))
+
+	if principal, err := call("/posts.BlogService/CreatePost", "Bearer "+token); err != nil || principal == nil || principal.Subject != "alice" {
+		t.Fatalf("expected alice to be authenticated, got %+v, %v", principal, err)
+	}
+	if _, err := call("/posts.BlogService/CreatePost", ""); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected %v without a token, got %v", codes.Unauthenticated, err)
+	}
+	if _, err := call("/posts.BlogService/CreatePost", "Basic "+token); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected %v for another scheme, got %v", codes.Unauthenticated, err)
+	}
+	if principal, err := call("/posts.BlogService/GetPost", ""); err != nil || principal != nil {
+		t.Fatalf("expected an anonymous GetPost, got %+v, %v", principal, err)
+	}
+	if _, err := call("/posts.BlogService/GetPost", "Bearer garbage"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected %v for an invalid token of an anonymous method, got %v", codes.Unauthenticated, err)
+	}
+}
@@ -0,0 +1,28 @@
+// Package auth authenticates the callers of the gRPC services.
+package auth
+
+import "context"
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Subject identifies the caller, it is the sub claim of a JWT.
+	Subject string
+	Roles   []Role
+	// ApiKeyId is the id of the API key the caller authenticated with, or
+	// "" for bearer tokens.
+	ApiKeyId string
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the call handled with ctx, or false if
+// the caller is anonymous.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
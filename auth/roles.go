@@ -0,0 +1,84 @@
+package auth
+
+import (
+	e "cloudbees/errors"
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Role is what a principal may do. Every role may do what the roles before
+// it may.
+type Role string
+
+const (
+	// RoleReader may read posts.
+	RoleReader Role = "reader"
+	// RoleAuthor may also write posts, but only change their own.
+	RoleAuthor Role = "author"
+	// RoleEditor may also change the posts of every author.
+	RoleEditor Role = "editor"
+	// RoleAdmin may also manage blogs.
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleReader: 1,
+	RoleAuthor: 2,
+	RoleEditor: 3,
+	RoleAdmin:  4,
+}
+
+// ParseRoles returns the known roles among names, or RoleReader when there
+// are none.
+func ParseRoles(names []string) []Role {
+	var roles []Role
+	for _, name := range names {
+		if _, known := roleRanks[Role(name)]; known {
+			roles = append(roles, Role(name))
+		}
+	}
+	if len(roles) == 0 {
+		return []Role{RoleReader}
+	}
+	return roles
+}
+
+// HasRole reports whether the principal has the role or one that may do more.
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if roleRanks[r] >= roleRanks[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer rejects calls whose principal lacks the role their method
+// requires. It runs after the Authenticator, so calls without a principal
+// are calls of methods open to anonymous callers, which it lets through.
+type Authorizer struct {
+	// required maps full method names to the role they require. Methods
+	// missing from it require RoleAdmin.
+	required map[string]Role
+}
+
+func NewAuthorizer(required map[string]Role) *Authorizer {
+	return &Authorizer{required: required}
+}
+
+// UnaryInterceptor authorizes unary calls.
+func (a *Authorizer) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if principal, ok := FromContext(ctx); ok {
+		required, known := a.required[info.FullMethod]
+		if !known {
+			required = RoleAdmin
+		}
+		if !principal.HasRole(required) {
+			return nil, status.Error(codes.PermissionDenied, e.PermissionDeniedError.Error())
+		}
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPrincipalHasRole(t *testing.T) {
+	editor := &Principal{Subject: "erin", Roles: ParseRoles([]string{"unknown", "editor"})}
+	if !editor.HasRole(RoleReader) || !editor.HasRole(RoleAuthor) || !editor.HasRole(RoleEditor) || editor.HasRole(RoleAdmin) {
+		t.Fatalf("expected an editor to have every role up to editor, got %v", editor.Roles)
+	}
+	nobody := &Principal{Subject: "nobody", Roles: ParseRoles(nil)}
+	if !nobody.HasRole(RoleReader) || nobody.HasRole(RoleAuthor) {
+		t.Fatalf("expected principals without roles to be readers, got %v", nobody.Roles)
+	}
+}
+
+func TestAuthorizerUnaryInterceptor(t *testing.T) {
+	authorizer := NewAuthorizer(map[string]Role{
+		"/posts.BlogService/GetPost":    RoleReader,
+		"/posts.BlogService/CreatePost": RoleAuthor,
+	})
+	call := func(principal *Principal, method string) error {
+		ctx := context.Background()
+		if principal != nil {
+			ctx = NewContext(ctx, principal)
+		}
+		_, err := authorizer.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	reader := &Principal{Subject: "carol", Roles: []Role{RoleReader}}
+	author := &Principal{Subject: "alice", Roles: []Role{RoleAuthor}}
+	admin := &Principal{Subject: "root", Roles: []Role{RoleAdmin}}
+
+	if err := call(reader, "/posts.BlogService/GetPost"); err != nil {
+		t.Fatalf("expected a reader to get posts, got %v", err)
+	}
+	if err := call(reader, "/posts.BlogService/CreatePost"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a reader's CreatePost to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if err := call(author, "/posts.BlogService/CreatePost"); err != nil {
+		t.Fatalf("expected an author to create posts, got %v", err)
+	}
+	if err := call(author, "/posts.BlogService/Unlisted"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected unlisted methods to need an admin, got %v", err)
+	}
+	if err := call(admin, "/posts.BlogService/Unlisted"); err != nil {
+		t.Fatalf("expected an admin to call unlisted methods, got %v", err)
+	}
+	if err := call(nil, "/posts.BlogService/GetPost"); err != nil {
+		t.Fatalf("expected anonymous calls to be left to the authenticator, got %v", err)
+	}
+}
@@ -0,0 +1,549 @@
+// Package config loads the server configuration from defaults, an optional
+// YAML or JSON file, environment variables and command line flags, each
+// overriding the ones before.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configEnv names the environment variable holding the config file path, for
+// when the -config flag isn't given.
+const configEnv = "BLOG_CONFIG"
+
+type Config struct {
+	// Listen is the host:port the gRPC server listens on.
+	Listen  string        `yaml:"listen" json:"listen"`
+	Storage StorageConfig `yaml:"storage" json:"storage"`
+	Log     LogConfig     `yaml:"log" json:"log"`
+	TLS     TLSConfig     `yaml:"tls" json:"tls"`
+	Limits  LimitsConfig  `yaml:"limits" json:"limits"`
+	Auth    AuthConfig    `yaml:"auth" json:"auth"`
+	Admin   AdminConfig   `yaml:"admin" json:"admin"`
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+	// DrainTimeout is how long a shutdown waits for in-flight calls to
+	// finish before cancelling them.
+	DrainTimeout Duration `yaml:"drain_timeout" json:"drain_timeout"`
+}
+
+type StorageConfig struct {
+	// Backend is memory, file or sqlite.
+	Backend string `yaml:"backend" json:"backend"`
+	// Path is the data file of the default blog, posts.wal or posts.db when
+	// empty.
+	Path           string   `yaml:"path" json:"path"`
+	TrashRetention Duration `yaml:"trash_retention" json:"trash_retention"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" json:"level"`
+	// Format is json or console.
+	Format string `yaml:"format" json:"format"`
+}
+
+// TLSConfig enables TLS when both files are set. The files are reloaded when
+// they change.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	// ClientCAFile is a PEM bundle of the CAs client certificates must be
+	// signed by. Clients without such a certificate are refused when it is set.
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"`
+}
+
+// Enabled reports whether the server should serve TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// LimitsConfig bounds what a client can ask of the server. Zero values leave
+// the gRPC defaults in place, or disable the limit.
+type LimitsConfig struct {
+	MaxRecvMsgBytes      int    `yaml:"max_recv_msg_bytes" json:"max_recv_msg_bytes"`
+	MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams" json:"max_concurrent_streams"`
+	// ReadRate and WriteRate are the calls per second each client may make
+	// of the methods that read and of the others, in bursts of up to
+	// ReadBurst and WriteBurst calls.
+	ReadRate   float64 `yaml:"read_rate" json:"read_rate"`
+	ReadBurst  int     `yaml:"read_burst" json:"read_burst"`
+	WriteRate  float64 `yaml:"write_rate" json:"write_rate"`
+	WriteBurst int     `yaml:"write_burst" json:"write_burst"`
+	// MaxInFlight is how many calls the server handles at once, shedding the
+	// calls beyond.
+	MaxInFlight int `yaml:"max_in_flight" json:"max_in_flight"`
+}
+
+// AuthConfig requires callers to authenticate once a way to do so is set.
+type AuthConfig struct {
+	JWT JWTConfig `yaml:"jwt" json:"jwt"`
+	// ApiKeys lets callers authenticate with API keys, which they manage
+	// with the ApiKeyService once authenticated with a bearer token.
+	ApiKeys bool `yaml:"api_keys" json:"api_keys"`
+	// AnonymousGetPost lets unauthenticated callers read posts with GetPost.
+	AnonymousGetPost bool `yaml:"anonymous_get_post" json:"anonymous_get_post"`
+}
+
+// Enabled reports whether callers must authenticate.
+func (c AuthConfig) Enabled() bool {
+	return c.JWT.Enabled()
+}
+
+// JWTConfig accepts bearer tokens signed with HS256 by the secret in
+// HMACSecretFile, or with RS256 or ES256 by a key of the JWKS in JWKSFile.
+type JWTConfig struct {
+	HMACSecretFile string `yaml:"hmac_secret_file" json:"hmac_secret_file"`
+	JWKSFile       string `yaml:"jwks_file" json:"jwks_file"`
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string `yaml:"issuer" json:"issuer"`
+	Audience string `yaml:"audience" json:"audience"`
+}
+
+func (c JWTConfig) Enabled() bool {
+	return c.HMACSecretFile != "" || c.JWKSFile != ""
+}
+
+// AdminConfig serves the operational endpoints, such as the Prometheus
+// metrics at /metrics, over HTTP apart from the gRPC service.
+type AdminConfig struct {
+	// Listen is the host:port of the admin endpoints, none are served when
+	// it is empty.
+	Listen string `yaml:"listen" json:"listen"`
+}
+
+// TracingConfig exports OpenTelemetry traces of the calls.
+type TracingConfig struct {
+	// Exporter is none, otlp, stdout or file.
+	Exporter string `yaml:"exporter" json:"exporter"`
+	// Endpoint is the host:port of the OTLP gRPC collector.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	// Insecure sends spans to the collector without TLS.
+	Insecure bool `yaml:"insecure" json:"insecure"`
+	// File is where the file exporter writes spans, as JSON.
+	File string `yaml:"file" json:"file"`
+	// SampleRatio is the share of traces sampled, other than those callers
+	// sampled, which always are.
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// Enabled reports whether traces are exported.
+func (c TracingConfig) Enabled() bool {
+	return c.Exporter != "none"
+}
+
+// Duration is a time.Duration written as a string like "1h30m" in files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default returns the configuration used for everything that isn't set.
+func Default() *Config {
+	return &Config{
+		Listen: ":8080",
+		Storage: StorageConfig{
+			Backend:        "memory",
+			TrashRetention: Duration(30 * 24 * time.Hour),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Limits: LimitsConfig{
+			ReadRate:    100,
+			ReadBurst:   200,
+			WriteRate:   20,
+			WriteBurst:  40,
+			MaxInFlight: 1024,
+		},
+		Admin: AdminConfig{
+			Listen: "localhost:9464",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4317",
+			SampleRatio: 1,
+		},
+		DrainTimeout: Duration(15 * time.Second),
+	}
+}
+
+// setting is a configuration value that can be set by a flag and an
+// environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{}
+}
+
+var settings = []setting{
+	{"listen", "BLOG_LISTEN", "host:port to serve gRPC on", func(c *Config) interface{} { return &c.Listen }},
+	{"storage", "BLOG_STORAGE", "post storage backend: memory, file or sqlite", func(c *Config) interface{} { return &c.Storage.Backend }},
+	{"data", "BLOG_DATA", "data file of the default blog of the file (default posts.wal) or sqlite (default posts.db) storage backend; other blogs are stored next to it", func(c *Config) interface{} { return &c.Storage.Path }},
+	{"trash-retention", "BLOG_TRASH_RETENTION", "how long deleted posts stay in the trash before they are purged", func(c *Config) interface{} { return &c.Storage.TrashRetention }},
+	{"log-level", "BLOG_LOG_LEVEL", "minimum level of logged messages: debug, info, warn or error", func(c *Config) interface{} { return &c.Log.Level }},
+	{"log-format", "BLOG_LOG_FORMAT", "log format: json or console", func(c *Config) interface{} { return &c.Log.Format }},
+	{"tls-cert", "BLOG_TLS_CERT", "PEM certificate file to serve TLS with", func(c *Config) interface{} { return &c.TLS.CertFile }},
+	{"tls-key", "BLOG_TLS_KEY", "PEM private key file of the TLS certificate", func(c *Config) interface{} { return &c.TLS.KeyFile }},
+	{"tls-client-ca", "BLOG_TLS_CLIENT_CA", "PEM CA bundle to require and verify client certificates with", func(c *Config) interface{} { return &c.TLS.ClientCAFile }},
+	{"max-recv-msg-bytes", "BLOG_MAX_RECV_MSG_BYTES", "largest request message the server accepts, 0 for the gRPC default", func(c *Config) interface{} { return &c.Limits.MaxRecvMsgBytes }},
+	{"max-concurrent-streams", "BLOG_MAX_CONCURRENT_STREAMS", "most concurrent calls per client connection, 0 for no limit", func(c *Config) interface{} { return &c.Limits.MaxConcurrentStreams }},
+	{"read-rate", "BLOG_READ_RATE", "calls per second each client may make of the methods that read, 0 for no limit", func(c *Config) interface{} { return &c.Limits.ReadRate }},
+	{"read-burst", "BLOG_READ_BURST", "most calls of the methods that read each client may make at once", func(c *Config) interface{} { return &c.Limits.ReadBurst }},
+	{"write-rate", "BLOG_WRITE_RATE", "calls per second each client may make of the other methods, 0 for no limit", func(c *Config) interface{} { return &c.Limits.WriteRate }},
+	{"write-burst", "BLOG_WRITE_BURST", "most calls of the other methods each client may make at once", func(c *Config) interface{} { return &c.Limits.WriteBurst }},
+	{"max-in-flight", "BLOG_MAX_IN_FLIGHT", "most calls the server handles at once, shedding the others, 0 for no limit", func(c *Config) interface{} { return &c.Limits.MaxInFlight }},
+	{"jwt-hmac-secret-file", "BLOG_JWT_HMAC_SECRET_FILE", "file holding the secret of HS256 signed bearer tokens", func(c *Config) interface{} { return &c.Auth.JWT.HMACSecretFile }},
+	{"jwt-jwks-file", "BLOG_JWT_JWKS_FILE", "JWKS file holding the public keys of RS256 and ES256 signed bearer tokens", func(c *Config) interface{} { return &c.Auth.JWT.JWKSFile }},
+	{"jwt-issuer", "BLOG_JWT_ISSUER", "issuer bearer tokens must name, if set", func(c *Config) interface{} { return &c.Auth.JWT.Issuer }},
+	{"jwt-audience", "BLOG_JWT_AUDIENCE", "audience bearer tokens must name, if set", func(c *Config) interface{} { return &c.Auth.JWT.Audience }},
+	{"api-keys", "BLOG_API_KEYS", "let callers authenticate with API keys, managed by callers with bearer tokens", func(c *Config) interface{} { return &c.Auth.ApiKeys }},
+	{"anonymous-get-post", "BLOG_ANONYMOUS_GET_POST", "let unauthenticated callers read posts with GetPost", func(c *Config) interface{} { return &c.Auth.AnonymousGetPost }},
+	{"admin-listen", "BLOG_ADMIN_LISTEN", "host:port to serve the admin endpoints such as /metrics on over HTTP, empty for none", func(c *Config) interface{} { return &c.Admin.Listen }},
+	{"tracing-exporter", "BLOG_TRACING_EXPORTER", "where to export traces: none, otlp, stdout or file", func(c *Config) interface{} { return &c.Tracing.Exporter }},
+	{"otlp-endpoint", "BLOG_OTLP_ENDPOINT", "host:port of the OTLP gRPC collector of the otlp trace exporter", func(c *Config) interface{} { return &c.Tracing.Endpoint }},
+	{"otlp-insecure", "BLOG_OTLP_INSECURE", "send traces to the OTLP collector without TLS", func(c *Config) interface{} { return &c.Tracing.Insecure }},
+	{"tracing-file", "BLOG_TRACING_FILE", "file the file trace exporter appends spans to as JSON", func(c *Config) interface{} { return &c.Tracing.File }},
+	{"tracing-sample-ratio", "BLOG_TRACING_SAMPLE_RATIO", "share of traces sampled, besides those callers sampled", func(c *Config) interface{} { return &c.Tracing.SampleRatio }},
+	{"drain-timeout", "BLOG_DRAIN_TIMEOUT", "how long shutdown waits for in-flight calls before cancelling them", func(c *Config) interface{} { return &c.DrainTimeout }},
+}
+
+// flagValue records the raw value of a flag, to be applied once the config
+// file and environment have been.
+type flagValue struct {
+	raw      *string
+	defValue string
+	isBool   bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.defValue
+}
+
+func (v *flagValue) Set(value string) error {
+	*v.raw = value
+	return nil
+}
+
+// IsBoolFlag lets boolean flags be given without a value.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// set parses value into the field pointed to by field.
+func set(field interface{}, value string) error {
+	switch field := field.(type) {
+	case *string:
+		*field = value
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*field = parsed
+	case *uint32:
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q is not a non-negative 32 bit integer", value)
+		}
+		*field = uint32(parsed)
+	case *float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field = parsed
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field = parsed
+	case *Duration:
+		if err := field.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", field))
+	}
+	return nil
+}
+
+// format returns the value of the field pointed to by field as set parses it.
+func format(field interface{}) string {
+	switch field := field.(type) {
+	case *string:
+		return *field
+	case *int:
+		return strconv.Itoa(*field)
+	case *uint32:
+		return strconv.FormatUint(uint64(*field), 10)
+	case *float64:
+		return strconv.FormatFloat(*field, 'g', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *Duration:
+		return time.Duration(*field).String()
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", field))
+	}
+}
+
+// Load builds the configuration from the command line arguments, without
+// the program name, and the environment looked up with lookupEnv. Flags
+// override environment variables, set ones even if empty, which override the
+// config file, which overrides the defaults. The result is validated.
+func Load(name string, args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	defaults := Default()
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	configPath := flags.String("config", "", "YAML or JSON config file (env "+configEnv+")")
+	raw := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		raw[s.flag] = new(string)
+		_, isBool := s.field(defaults).(*bool)
+		flags.Var(&flagValue{raw: raw[s.flag], defValue: format(s.field(defaults)), isBool: isBool}, s.flag, s.usage+" (env "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv(configEnv)
+	}
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	var errs []error
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok {
+			if err := set(s.field(config), value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if given[s.flag] {
+			if err := set(s.field(config), *raw[s.flag]); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if config.Storage.Path == "" {
+		switch config.Storage.Backend {
+		case "file":
+			config.Storage.Path = "posts.wal"
+		case "sqlite":
+			config.Storage.Path = "posts.db"
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadFile reads the YAML or JSON file at path over the config, rejecting
+// unknown fields. Files ending in .json are JSON, all others YAML.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+		if errors.Is(err, io.EOF) {
+			// An empty file sets nothing.
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting of the config.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if err := validateAddress(c.Listen); err != nil {
+		invalid("listen: %v", err)
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+	case "file", "sqlite":
+		if c.Storage.Path == "" {
+			invalid("storage.path: the %s backend needs a data file", c.Storage.Backend)
+		}
+	default:
+		invalid("storage.backend: %q is not memory, file or sqlite", c.Storage.Backend)
+	}
+	if c.Storage.TrashRetention <= 0 {
+		invalid("storage.trash_retention: must be positive, got %s", time.Duration(c.Storage.TrashRetention))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level: %q is not debug, info, warn or error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "json", "console":
+	default:
+		invalid("log.format: %q is not json or console", c.Log.Format)
+	}
+
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			invalid("tls: cert_file and key_file must be set together")
+		}
+		for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile, c.TLS.ClientCAFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				invalid("tls: %v", err)
+			}
+		}
+	} else if c.TLS.ClientCAFile != "" {
+		invalid("tls: client_ca_file needs cert_file and key_file")
+	}
+
+	for _, file := range []string{c.Auth.JWT.HMACSecretFile, c.Auth.JWT.JWKSFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			invalid("auth.jwt: %v", err)
+		}
+	}
+	if c.Auth.ApiKeys && !c.Auth.JWT.Enabled() {
+		invalid("auth.api_keys: needs auth.jwt, whose bearer tokens manage the keys")
+	}
+	if c.Auth.AnonymousGetPost && !c.Auth.Enabled() {
+		invalid("auth.anonymous_get_post: needs a way to authenticate, such as auth.jwt")
+	}
+
+	if c.Limits.MaxRecvMsgBytes < 0 {
+		invalid("limits.max_recv_msg_bytes: must not be negative, got %d", c.Limits.MaxRecvMsgBytes)
+	}
+	for _, rate := range []struct {
+		name  string
+		rate  float64
+		burst int
+	}{{"read", c.Limits.ReadRate, c.Limits.ReadBurst}, {"write", c.Limits.WriteRate, c.Limits.WriteBurst}} {
+		if rate.rate < 0 {
+			invalid("limits.%s_rate: must not be negative, got %g", rate.name, rate.rate)
+		} else if rate.rate > 0 && rate.burst < 1 {
+			invalid("limits.%s_burst: must be at least 1, got %d", rate.name, rate.burst)
+		}
+	}
+	if c.Limits.MaxInFlight < 0 {
+		invalid("limits.max_in_flight: must not be negative, got %d", c.Limits.MaxInFlight)
+	}
+	if c.Admin.Listen != "" {
+		if err := validateAddress(c.Admin.Listen); err != nil {
+			invalid("admin.listen: %v", err)
+		} else if sameAddress(c.Admin.Listen, c.Listen) {
+			invalid("admin.listen: %q takes the port of listen %q", c.Admin.Listen, c.Listen)
+		}
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if err := validateAddress(c.Tracing.Endpoint); err != nil {
+			invalid("tracing.endpoint: %v", err)
+		}
+	case "file":
+		if c.Tracing.File == "" {
+			invalid("tracing.file: the file exporter needs a file")
+		}
+	default:
+		invalid("tracing.exporter: %q is not none, otlp, stdout or file", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio: must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+	if c.DrainTimeout < 0 {
+		invalid("drain_timeout: must not be negative, got %s", time.Duration(c.DrainTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// validateAddress fails unless address is a host:port address to listen on.
+func validateAddress(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%q is not a host:port address", address)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+	return nil
+}
+
+// sameAddress reports whether listening on both host:port addresses would
+// take the same port, as they name the same host or one names every host.
+func sameAddress(a string, b string) bool {
+	hostA, portA, _ := net.SplitHostPort(a)
+	hostB, portB, _ := net.SplitHostPort(b)
+	if portA != portB {
+		return false
+	}
+	every := func(host string) bool {
+		return host == "" || host == "0.0.0.0" || host == "::"
+	}
+	return hostA == hostB || every(hostA) || every(hostB)
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, err := Load("test", nil, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if config.Listen != ":8080" || config.Storage.Backend != "memory" || config.Log.Level != "info" || config.Log.Format != "json" {
+		t.Errorf("unexpected defaults: %+v", config)
+	}
+	if time.Duration(config.Storage.TrashRetention) != 30*24*time.Hour {
+		t.Errorf("expected 720h trash retention, got %s", time.Duration(config.Storage.TrashRetention))
+	}
+	if config.TLS.Enabled() {
+		t.Errorf("expected TLS to be off by default")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+listen: "localhost:7000"
+storage:
+  backend: file
+  trash_retention: 48h
+log:
+  level: debug
+`)
+	config, err := Load("test",
+		[]string{"-config", path, "-listen", "localhost:9000"},
+		env(map[string]string{"BLOG_LISTEN": "localhost:8000", "BLOG_LOG_LEVEL": "warn"}),
+		io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if config.Listen != "localhost:9000" {
+		t.Errorf("expected the flag to win, got listen %q", config.Listen)
+	}
+	if config.Log.Level != "warn" {
+		t.Errorf("expected the environment to override the file, got level %q", config.Log.Level)
+	}
+	if config.Storage.Backend != "file" || time.Duration(config.Storage.TrashRetention) != 48*time.Hour {
+		t.Errorf("expected the file to override the defaults, got %+v", config.Storage)
+	}
+	if config.Storage.Path != "posts.wal" {
+		t.Errorf("expected the default path of the file backend, got %q", config.Storage.Path)
+	}
+}
+
+func TestLoadJSONFileFromEnvironment(t *testing.T) {
+	path := writeFile(t, "config.json", `{"storage": {"backend": "sqlite", "path": "blog.db"}, "limits": {"max_concurrent_streams": 100}}`)
+	config, err := Load("test", nil, env(map[string]string{"BLOG_CONFIG": path}), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if config.Storage.Backend != "sqlite" || config.Storage.Path != "blog.db" {
+		t.Errorf("unexpected storage: %+v", config.Storage)
+	}
+	if config.Limits.MaxConcurrentStreams != 100 {
+		t.Errorf("expected 100 concurrent streams, got %d", config.Limits.MaxConcurrentStreams)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "listen: \":8080\"\nlisten_port: 80\n",
+		"config.json": `{"listen": ":8080", "listen_port": 80}`,
+	} {
+		path := writeFile(t, name, content)
+		if _, err := Load("test", []string{"-config", path}, env(nil), io.Discard); err == nil || !strings.Contains(err.Error(), "listen_port") {
+			t.Errorf("%s: expected an error naming the unknown field, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadReportsEveryInvalidSetting(t *testing.T) {
+	_, err := Load("test",
+		[]string{"-listen", "8080", "-storage", "disk", "-log-format", "xml", "-tls-cert", "missing.pem"},
+		env(nil),
+		io.Discard)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"listen", "storage.backend", "log.format", "tls: cert_file and key_file", "missing.pem"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error about %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	_, err := Load("test",
+		[]string{"-trash-retention", "a week"},
+		env(map[string]string{"BLOG_MAX_RECV_MSG_BYTES": "lots"}),
+		io.Discard)
+	if err == nil {
+		t.Fatalf("expected parse errors")
+	}
+	for _, want := range []string{"-trash-retention", "BLOG_MAX_RECV_MSG_BYTES"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error about %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsClientCAWithoutCertificate(t *testing.T) {
+	ca := writeFile(t, "ca.pem", "")
+	_, err := Load("test", []string{"-tls-client-ca", ca}, env(nil), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "client_ca_file") {
+		t.Fatalf("expected an error about the client CA bundle, got %v", err)
+	}
+}
+
+func TestLoadAuth(t *testing.T) {
+	secret := writeFile(t, "secret", "0123456789abcdef0123456789abcdef")
+	config, err := Load("test", []string{"-jwt-hmac-secret-file", secret, "-anonymous-get-post"}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !config.Auth.Enabled() || !config.Auth.AnonymousGetPost {
+		t.Errorf("expected authentication with anonymous GetPost, got %+v", config.Auth)
+	}
+
+	_, err = Load("test", nil, env(map[string]string{"BLOG_ANONYMOUS_GET_POST": "true"}), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "anonymous_get_post") {
+		t.Fatalf("expected anonymous GetPost without authentication to be rejected, got %v", err)
+	}
+}
+
+func TestLoadRejectsApiKeysWithoutJWT(t *testing.T) {
+	_, err := Load("test", []string{"-api-keys"}, env(nil), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "auth.api_keys") {
+		t.Fatalf("expected API keys without bearer tokens to be rejected, got %v", err)
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	config, err := Load("test", []string{"-read-rate", "2.5", "-write-rate", "0"}, env(map[string]string{"BLOG_MAX_IN_FLIGHT": "64"}), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if config.Limits.ReadRate != 2.5 || config.Limits.WriteRate != 0 || config.Limits.MaxInFlight != 64 {
+		t.Errorf("expected a read rate of 2.5, no write rate and 64 calls in flight, got %+v", config.Limits)
+	}
+
+	_, err = Load("test", []string{"-read-rate", "NaN"}, env(nil), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "-read-rate") {
+		t.Fatalf("expected a rate that isn't a number to be rejected, got %v", err)
+	}
+	_, err = Load("test", []string{"-read-rate", "-1", "-write-burst", "0", "-max-in-flight", "-1"}, env(nil), io.Discard)
+	if err == nil {
+		t.Fatalf("expected invalid rate limits to be rejected")
+	}
+	for _, want := range []string{"limits.read_rate", "limits.write_burst", "limits.max_in_flight"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error about %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadAdmin(t *testing.T) {
+	config, err := Load("test", []string{"-admin-listen", ""}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if config.Admin.Listen != "" {
+		t.Errorf("expected no admin endpoints, got %q", config.Admin.Listen)
+	}
+	config, err = Load("test", nil, env(map[string]string{"BLOG_ADMIN_LISTEN": ""}), io.Discard)
+	if err != nil || config.Admin.Listen != "" {
+		t.Errorf("expected an empty BLOG_ADMIN_LISTEN to turn the admin endpoints off, got %q, %v", config.Admin.Listen, err)
+	}
+
+	for _, admin := range []string{":8080", "localhost:8080"} {
+		_, err = Load("test", []string{"-admin-listen", admin}, env(nil), io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "admin.listen") {
+			t.Fatalf("expected the admin endpoints on %s, the gRPC port, to be rejected, got %v", admin, err)
+		}
+	}
+	if _, err := Load("test", []string{"-listen", ":9090"}, env(nil), io.Discard); err != nil {
+		t.Fatalf("expected the default admin address to leave other ports free, got %v", err)
+	}
+}
+
+func TestLoadTracing(t *testing.T) {
+	config, err := Load("test", []string{"-tracing-exporter", "otlp", "-otlp-endpoint", "collector:4317"}, env(map[string]string{"BLOG_TRACING_SAMPLE_RATIO": "0.25"}), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !config.Tracing.Enabled() || config.Tracing.Endpoint != "collector:4317" || config.Tracing.SampleRatio != 0.25 {
+		t.Errorf("expected OTLP tracing to collector:4317 sampling a quarter, got %+v", config.Tracing)
+	}
+
+	_, err = Load("test", []string{"-tracing-exporter", "file", "-tracing-sample-ratio", "2"}, env(nil), io.Discard)
+	if err == nil {
+		t.Fatalf("expected invalid tracing settings to be rejected")
+	}
+	for _, want := range []string{"tracing.file", "tracing.sample_ratio"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error about %s, got:\n%v", want, err)
+		}
+	}
+}
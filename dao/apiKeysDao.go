@@ -0,0 +1,200 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ApiKeyDAO keeps API keys in memory and, when it has a path, in a JSON file
+// rewritten on every change.
+type ApiKeyDAO struct {
+	mu   sync.RWMutex
+	keys map[string]*m.ApiKey
+	path string
+}
+
+// NewApiKeyDAO returns an empty in-memory ApiKeyDAO.
+func NewApiKeyDAO() *ApiKeyDAO {
+	return &ApiKeyDAO{keys: make(map[string]*m.ApiKey)}
+}
+
+// NewFileApiKeyDAO returns an ApiKeyDAO persisting its keys to the file at
+// path, loading the keys already in it.
+func NewFileApiKeyDAO(path string) (*ApiKeyDAO, error) {
+	dao := NewApiKeyDAO()
+	dao.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dao, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*m.ApiKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("cannot parse API keys %s: %w", path, err)
+	}
+	for _, key := range keys {
+		dao.keys[key.KeyId] = key
+	}
+	return dao, nil
+}
+
+func copyApiKey(key *m.ApiKey) *m.ApiKey {
+	copied := *key
+	copied.Roles = append([]string(nil), key.Roles...)
+	copied.Salt = append([]byte(nil), key.Salt...)
+	copied.Hash = append([]byte(nil), key.Hash...)
+	return &copied
+}
+
+// list returns the stored keys in ascending creation order. Callers hold mu.
+func (dao *ApiKeyDAO) list() []*m.ApiKey {
+	keys := make([]*m.ApiKey, 0, len(dao.keys))
+	for _, key := range dao.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].KeyId < keys[j].KeyId
+	})
+	return keys
+}
+
+// save writes every key to the file, if any, replacing it atomically.
+// Callers hold mu for writing.
+func (dao *ApiKeyDAO) save() error {
+	if dao.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(dao.list())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dao.path), filepath.Base(dao.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dao.path)
+}
+
+// put stores the key and saves, restoring the previous key if saving fails.
+// Callers hold mu for writing.
+func (dao *ApiKeyDAO) put(key *m.ApiKey) error {
+	previous, existed := dao.keys[key.KeyId]
+	dao.keys[key.KeyId] = copyApiKey(key)
+	if err := dao.save(); err != nil {
+		if existed {
+			dao.keys[key.KeyId] = previous
+		} else {
+			delete(dao.keys, key.KeyId)
+		}
+		return err
+	}
+	return nil
+}
+
+func (dao *ApiKeyDAO) Create(key *m.ApiKey) error {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	if _, exists := dao.keys[key.KeyId]; exists {
+		return e.EntityAlreadyExistsError
+	}
+	return dao.put(key)
+}
+
+func (dao *ApiKeyDAO) Read(id string) (*m.ApiKey, error) {
+	dao.mu.RLock()
+	defer dao.mu.RUnlock()
+	key, exists := dao.keys[id]
+	if !exists {
+		return nil, e.EnitityNotFoundError
+	}
+	return copyApiKey(key), nil
+}
+
+func (dao *ApiKeyDAO) Update(key *m.ApiKey) error {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	if _, exists := dao.keys[key.KeyId]; !exists {
+		return e.EnitityNotFoundError
+	}
+	return dao.put(key)
+}
+
+func (dao *ApiKeyDAO) Delete(id string) error {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	key, exists := dao.keys[id]
+	if !exists {
+		return e.EnitityNotFoundError
+	}
+	delete(dao.keys, id)
+	if err := dao.save(); err != nil {
+		dao.keys[id] = key
+		return err
+	}
+	return nil
+}
+
+func (dao *ApiKeyDAO) List() ([]*m.ApiKey, error) {
+	dao.mu.RLock()
+	defer dao.mu.RUnlock()
+	keys := dao.list()
+	for i, key := range keys {
+		keys[i] = copyApiKey(key)
+	}
+	return keys, nil
+}
+
+func (dao *ApiKeyDAO) Touch(id string, usedAt time.Time) error {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	key, exists := dao.keys[id]
+	if !exists {
+		return e.EnitityNotFoundError
+	}
+	touched := copyApiKey(key)
+	touched.LastUsedAt = usedAt
+	return dao.put(touched)
+}
+
+func (dao *ApiKeyDAO) Revoke(id string, revokedAt time.Time) (*m.ApiKey, error) {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	key, exists := dao.keys[id]
+	if !exists {
+		return nil, e.EnitityNotFoundError
+	}
+	if key.RevokedAt.IsZero() {
+		revoked := copyApiKey(key)
+		revoked.RevokedAt = revokedAt
+		if err := dao.put(revoked); err != nil {
+			return nil, err
+		}
+	}
+	return copyApiKey(dao.keys[id]), nil
+}
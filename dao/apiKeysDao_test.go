@@ -0,0 +1,81 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestApiKey(id string, createdAt time.Time) *m.ApiKey {
+	return &m.ApiKey{
+		KeyId:     id,
+		Name:      "Key " + id,
+		Owner:     "alice",
+		Roles:     []string{"author"},
+		Scope:     "write",
+		Salt:      []byte("salt"),
+		Hash:      []byte("hash"),
+		CreatedAt: createdAt,
+	}
+}
+
+func TestFileApiKeyDAOPersistsKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.apikeys.json")
+	keys, err := NewFileApiKeyDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open API keys: %v", err)
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"b", "a"} {
+		if err := keys.Create(newTestApiKey(id, created.Add(time.Duration(i)*time.Hour))); err != nil {
+			t.Fatalf("failed to create key: %v", err)
+		}
+	}
+	if err := keys.Create(newTestApiKey("a", created)); !errors.Is(err, e.EntityAlreadyExistsError) {
+		t.Fatalf("expected %v for a taken id, got %v", e.EntityAlreadyExistsError, err)
+	}
+	revoked, err := keys.Revoke("b", created.Add(48*time.Hour))
+	if err != nil || !revoked.RevokedAt.Equal(created.Add(48*time.Hour)) {
+		t.Fatalf("failed to revoke key: %+v, %v", revoked, err)
+	}
+	if again, err := keys.Revoke("b", created.Add(96*time.Hour)); err != nil || !again.RevokedAt.Equal(revoked.RevokedAt) {
+		t.Fatalf("expected revoking again to keep the first revocation, got %+v, %v", again, err)
+	}
+	if _, err := keys.Revoke("c", created); !errors.Is(err, e.EnitityNotFoundError) {
+		t.Fatalf("expected %v revoking an unknown key, got %v", e.EnitityNotFoundError, err)
+	}
+	used := created.Add(72 * time.Hour)
+	if err := keys.Touch("b", used); err != nil {
+		t.Fatalf("failed to touch key: %v", err)
+	}
+
+	reopened, err := NewFileApiKeyDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen API keys: %v", err)
+	}
+	list, err := reopened.List()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	if len(list) != 2 || list[0].KeyId != "b" || list[1].KeyId != "a" {
+		t.Fatalf("expected keys b and a in creation order, got %+v", list)
+	}
+	if !list[0].RevokedAt.Equal(revoked.RevokedAt) || !list[0].LastUsedAt.Equal(used) {
+		t.Fatalf("expected the touch to keep the revocation, got %+v", list[0])
+	}
+
+	list[1].Roles[0] = "admin"
+	key, err := reopened.Read("a")
+	if err != nil || key.Roles[0] != "author" {
+		t.Fatalf("expected the stored key to be unchanged, got %+v, %v", key, err)
+	}
+	if err := reopened.Delete("a"); err != nil {
+		t.Fatalf("failed to delete key: %v", err)
+	}
+	if _, err := reopened.Read("a"); !errors.Is(err, e.EnitityNotFoundError) {
+		t.Fatalf("expected %v after delete, got %v", e.EnitityNotFoundError, err)
+	}
+}
@@ -0,0 +1,210 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBlog is the blog of requests that don't name one. It always exists.
+const DefaultBlog = "default"
+
+var blogIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidateBlogId fails with errors.InvalidBlogIdError unless id can name a
+// blog. Blog ids are safe to use in file names.
+func ValidateBlogId(id string) error {
+	if !blogIdPattern.MatchString(id) {
+		return e.InvalidBlogIdError
+	}
+	return nil
+}
+
+// BlogRepository keeps the posts of every blog in a PostRepository of its
+// own, so every blog has its own post id space. The operations of the blogs
+// are recorded in the metrics of Collectors.
+//
+// Deleting a blog closes its repository; requests still working on it fail.
+type BlogRepository struct {
+	mu    sync.RWMutex
+	blogs map[string]PostRepository
+	// open creates the repository of a new blog, or opens the one of a blog
+	// that was created before, and remove deletes what open created.
+	open   func(blog string) (PostRepository, error)
+	remove func(blog string) error
+}
+
+// NewBlogRepository returns a BlogRepository whose default blog keeps its
+// posts in posts and whose other blogs are kept in memory.
+func NewBlogRepository(posts PostRepository) *BlogRepository {
+	return &BlogRepository{
+		blogs: map[string]PostRepository{DefaultBlog: instrument(posts)},
+		open: func(blog string) (PostRepository, error) {
+			return instrument(NewPostDAO()), nil
+		},
+		remove: func(blog string) error {
+			return nil
+		},
+	}
+}
+
+// NewMemoryBlogRepository returns a BlogRepository keeping every blog in memory.
+func NewMemoryBlogRepository() *BlogRepository {
+	return NewBlogRepository(NewPostDAO())
+}
+
+// NewFileBlogRepository returns a BlogRepository keeping every blog in a
+// write-ahead log: the default blog at path and the others next to it, with
+// the blog id before the extension of path.
+func NewFileBlogRepository(path string) (*BlogRepository, error) {
+	return openBlogFiles(path, func(path string) (PostRepository, error) {
+		return NewFilePostDAO(path)
+	})
+}
+
+// NewSQLiteBlogRepository returns a BlogRepository keeping every blog in a
+// SQLite database: the default blog at path and the others next to it, with
+// the blog id before the extension of path.
+func NewSQLiteBlogRepository(path string) (*BlogRepository, error) {
+	return openBlogFiles(path, func(path string) (PostRepository, error) {
+		return NewSQLitePostDAO(path)
+	})
+}
+
+// blogPath returns where the blog is stored, given the path of the default blog.
+func blogPath(path string, blog string) string {
+	if blog == DefaultBlog {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + blog + ext
+}
+
+// openBlogFiles opens the blogs stored in files next to path, the file of
+// the default blog.
+func openBlogFiles(path string, open func(path string) (PostRepository, error)) (*BlogRepository, error) {
+	repo := &BlogRepository{
+		blogs: make(map[string]PostRepository),
+		open: func(blog string) (PostRepository, error) {
+			posts, err := open(blogPath(path, blog))
+			if err != nil {
+				return nil, err
+			}
+			return instrument(posts), nil
+		},
+		remove: func(blog string) error {
+			return os.Remove(blogPath(path, blog))
+		},
+	}
+
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext) + "."
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	blogs := []string{DefaultBlog}
+	for _, match := range matches {
+		blog := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if blog != DefaultBlog && ValidateBlogId(blog) == nil {
+			blogs = append(blogs, blog)
+		}
+	}
+	for _, blog := range blogs {
+		posts, err := repo.open(blog)
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+		repo.blogs[blog] = posts
+	}
+	return repo, nil
+}
+
+// Blog returns the posts of the blog.
+func (repo *BlogRepository) Blog(id string) (PostRepository, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	posts, exists := repo.blogs[id]
+	if !exists {
+		return nil, e.BlogNotFoundError
+	}
+	return posts, nil
+}
+
+// Blogs returns the ids of the blogs in ascending order.
+func (repo *BlogRepository) Blogs() []string {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	ids := make([]string, 0, len(repo.blogs))
+	for id := range repo.blogs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (repo *BlogRepository) CreateBlog(id string) error {
+	if err := ValidateBlogId(id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, exists := repo.blogs[id]; exists {
+		return e.BlogAlreadyExistsError
+	}
+	posts, err := repo.open(id)
+	if err != nil {
+		return err
+	}
+	repo.blogs[id] = posts
+	return nil
+}
+
+// DeleteBlog deletes the blog along with all of its posts. A blog whose
+// storage can't be removed is reopened and kept.
+func (repo *BlogRepository) DeleteBlog(id string) error {
+	if id == DefaultBlog {
+		return e.DefaultBlogDeletionError
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	posts, exists := repo.blogs[id]
+	if !exists {
+		return e.BlogNotFoundError
+	}
+	if closer, ok := posts.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	if err := repo.remove(id); err != nil {
+		if reopened, openErr := repo.open(id); openErr == nil {
+			repo.blogs[id] = reopened
+		}
+		return err
+	}
+	delete(repo.blogs, id)
+	return nil
+}
+
+// Close closes the repositories of every blog. The BlogRepository must not
+// be used afterwards.
+func (repo *BlogRepository) Close() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var first error
+	for _, posts := range repo.blogs {
+		if closer, ok := posts.(io.Closer); ok {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
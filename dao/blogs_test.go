@@ -0,0 +1,122 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlogRepositoryIdSpaces(t *testing.T) {
+	repo := NewMemoryBlogRepository()
+	if err := repo.CreateBlog("team-a"); err != nil {
+		t.Fatalf("failed to create blog: %v", err)
+	}
+	defaultBlog, _ := repo.Blog(DefaultBlog)
+	teamA, _ := repo.Blog("team-a")
+	defaultBlog.Create(newTestPost(0, "Default"))
+	defaultBlog.Create(newTestPost(0, "Default"))
+	post := newTestPost(0, "Team A")
+	if err := teamA.Create(post); err != nil || post.PostId != 1 {
+		t.Fatalf("expected the blog's first generated id 1, got %d, %v", post.PostId, err)
+	}
+	if read, _ := defaultBlog.Read(1); read.Title != "Default" {
+		t.Fatalf("expected blogs to keep their own posts, got %+v", read)
+	}
+
+	for _, c := range []struct {
+		id       string
+		expected error
+	}{
+		{"team-a", e.BlogAlreadyExistsError},
+		{DefaultBlog, e.BlogAlreadyExistsError},
+		{"Team A", e.InvalidBlogIdError},
+		{"../team-b", e.InvalidBlogIdError},
+		{"", e.InvalidBlogIdError},
+	} {
+		if err := repo.CreateBlog(c.id); err != c.expected {
+			t.Errorf("expected %v creating blog %q, got %v", c.expected, c.id, err)
+		}
+	}
+	if err := repo.DeleteBlog(DefaultBlog); err != e.DefaultBlogDeletionError {
+		t.Fatalf("expected %v deleting the default blog, got %v", e.DefaultBlogDeletionError, err)
+	}
+	if err := repo.DeleteBlog("team-a"); err != nil {
+		t.Fatalf("failed to delete blog: %v", err)
+	}
+	if _, err := repo.Blog("team-a"); err != e.BlogNotFoundError {
+		t.Fatalf("expected %v for a deleted blog, got %v", e.BlogNotFoundError, err)
+	}
+	if blogs := fmt.Sprint(repo.Blogs()); blogs != "[default]" {
+		t.Fatalf("expected only the default blog to be left, got %s", blogs)
+	}
+}
+
+func TestFileBlogRepositoryPersists(t *testing.T) {
+	for name, open := range map[string]func(path string) (*BlogRepository, error){
+		"file":   NewFileBlogRepository,
+		"sqlite": NewSQLiteBlogRepository,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "posts.data")
+			repo, err := open(path)
+			if err != nil {
+				t.Fatalf("failed to open blogs: %v", err)
+			}
+			repo.CreateBlog("team-a")
+			repo.CreateBlog("team-b")
+			teamA, _ := repo.Blog("team-a")
+			teamA.Create(newTestPost(1, "Team A"))
+			if err := repo.DeleteBlog("team-b"); err != nil {
+				t.Fatalf("failed to delete blog: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), "posts.team-b.data")); !os.IsNotExist(err) {
+				t.Fatalf("expected the deleted blog's file to be removed, got %v", err)
+			}
+			repo.Close()
+
+			repo, err = open(path)
+			if err != nil {
+				t.Fatalf("failed to reopen blogs: %v", err)
+			}
+			defer repo.Close()
+			if blogs := fmt.Sprint(repo.Blogs()); blogs != "[default team-a]" {
+				t.Fatalf("expected blogs to be found again, got %s", blogs)
+			}
+			teamA, _ = repo.Blog("team-a")
+			if post, err := teamA.Read(1); err != nil || post.Title != "Team A" {
+				t.Fatalf("expected the blog's post to persist, got %+v, %v", post, err)
+			}
+		})
+	}
+}
+
+func TestFileBlogRepositoryKeepsBlogsFailingRemoval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+	repo, err := NewFileBlogRepository(path)
+	if err != nil {
+		t.Fatalf("failed to open blogs: %v", err)
+	}
+	defer repo.Close()
+	repo.CreateBlog("team-a")
+	teamA, _ := repo.Blog("team-a")
+	teamA.Create(newTestPost(1, "Team A"))
+
+	remove := repo.remove
+	repo.remove = func(blog string) error { return os.ErrPermission }
+	if err := repo.DeleteBlog("team-a"); err != os.ErrPermission {
+		t.Fatalf("expected the failed removal, got %v", err)
+	}
+	teamA, err = repo.Blog("team-a")
+	if err != nil {
+		t.Fatalf("expected the blog to be kept, got %v", err)
+	}
+	if post, err := teamA.Read(1); err != nil || post.Title != "Team A" {
+		t.Fatalf("expected the kept blog to be usable, got %+v, %v", post, err)
+	}
+	repo.remove = remove
+	if err := repo.DeleteBlog("team-a"); err != nil {
+		t.Fatalf("failed to delete blog: %v", err)
+	}
+}
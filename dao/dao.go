@@ -1,8 +1,113 @@
 package dao
 
-type DAO interface {
-	Create(entity interface{}) error
-	Read(id int32) (interface{}, error)
-	Update(id int32, updatedEntity interface{}) error
-	Delete(id int32) error
+import (
+	m "cloudbees/models"
+	"math"
+	"time"
+)
+
+// maxGeneratedId is the highest id Create allocates. SQLite stores ids as
+// signed integers, so the ids above it are the ones stored as negatives.
+const maxGeneratedId = math.MaxInt64
+
+// Repository stores entities of type T keyed by ids of type K. Create returns
+// errors.EntityAlreadyExistsError if the id is taken; Read, Update and Delete
+// return errors.EnitityNotFoundError for unknown ids.
+type Repository[K comparable, T any] interface {
+	Create(entity T) error
+	Read(id K) (T, error)
+	Update(entity T) error
+	Delete(id K) error
 }
+
+// PostRepository is the post store the services are built on. It keeps its
+// own copies of posts: changing a post passed to or returned by a repository
+// never changes what is stored.
+//
+// Create allocates the post id when the post's PostId is 0: ids come from a
+// sequence persisted with the store that always exceeds every id created so
+// far, including ids chosen by clients and ids of deleted posts. Ids above
+// maxGeneratedId are left to clients: they never advance the sequence, so a
+// client choosing one cannot exhaust the ids Create allocates.
+//
+// Posts are versioned for optimistic concurrency control: Create stores a post
+// at version 1 and every Update bumps the version by one, setting the new
+// version on the given post. An Update of a post with a non-zero Version
+// fails with errors.VersionConflictError unless it matches the stored version.
+//
+// Posts moved to the trash are left out of Read, Update, Scan, Search,
+// ListRevisions and ReadRevision as if they were deleted, but keep their id: Create and Upsert fail with
+// errors.EntityAlreadyExistsError for it until the post is purged.
+type PostRepository interface {
+	Repository[uint64, *m.Post]
+	// Upsert creates the post, or replaces it if the id is taken. A non-zero
+	// Version must match the stored version, as for Update.
+	Upsert(post *m.Post) error
+	// DeleteVersion permanently deletes the post like Delete, whether or not
+	// it is in the trash, but fails with
+	// errors.VersionConflictError if version is non-zero and doesn't match
+	// the stored version.
+	DeleteVersion(id uint64, version uint64) error
+	// Scan calls fn for every post with an id greater than afterId in
+	// ascending id order, stopping as soon as fn returns false.
+	Scan(afterId uint64, fn func(post *m.Post) bool) error
+	// FindPosts calls fn for every post matching filter with an id greater
+	// than afterId in ascending id order, stopping as soon as fn returns
+	// false. Posts are looked up through secondary indexes on author, tag
+	// and publication date rather than by scanning every post.
+	FindPosts(filter PostFilter, afterId uint64, fn func(post *m.Post) bool) error
+	// FindPostsDescending is FindPosts in descending id order, calling fn
+	// for the posts with an id less than beforeId, or for all of them if
+	// beforeId is 0.
+	FindPostsDescending(filter PostFilter, beforeId uint64, fn func(post *m.Post) bool) error
+	// FindPostsByPublicationDate calls fn for every post matching filter in
+	// publication date order, breaking ties by id, descending if desc is set.
+	// A non-nil after resumes right after the post at that position.
+	FindPostsByPublicationDate(filter PostFilter, desc bool, after *PostCursor, fn func(post *m.Post) bool) error
+	// Search returns the posts matching a full-text query over their title,
+	// content and tags, best match first, skipping offset hits and returning
+	// at most limit, along with the total number of matches. Words in quotes
+	// match as a phrase and a word ending in * matches as a prefix.
+	Search(query string, offset int, limit int) ([]SearchHit, int, error)
+	// ListRevisions returns the revisions of the post, oldest first. Every
+	// Create, Update and Upsert stores the written post as a new revision,
+	// numbered by its version.
+	ListRevisions(id uint64) ([]*m.PostRevision, error)
+	// ReadRevision returns the revision of the post at the given version.
+	ReadRevision(id uint64, version uint64) (*m.PostRevision, error)
+	// Trash moves the post to the trash, recording who deleted it and when.
+	// A non-zero version must match the stored version, as for DeleteVersion.
+	Trash(id uint64, version uint64, deletedBy string, deletedAt time.Time) error
+	// Restore takes the post out of the trash.
+	Restore(id uint64) error
+	// Purge permanently deletes the post if it is in the trash, and fails
+	// with errors.EnitityNotFoundError otherwise.
+	Purge(id uint64) error
+	// ScanDeleted is Scan over the posts in the trash.
+	ScanDeleted(afterId uint64, fn func(post *m.Post) bool) error
+	// Count returns the number of posts, leaving out those in the trash.
+	Count() (int, error)
+}
+
+// ApiKeyRepository stores API keys by key id. Like PostRepository it keeps
+// its own copies of keys.
+type ApiKeyRepository interface {
+	Repository[string, *m.ApiKey]
+	// List returns every key, revoked and expired ones included, in
+	// ascending creation order.
+	List() ([]*m.ApiKey, error)
+	// Touch records that the key was used at usedAt, changing nothing else,
+	// so it can't undo a concurrent Update.
+	Touch(id string, usedAt time.Time) error
+	// Revoke records that the key was revoked at revokedAt unless it already
+	// was, changing nothing else, and returns the stored key.
+	Revoke(id string, revokedAt time.Time) (*m.ApiKey, error)
+}
+
+// isDeleted reports whether the post is in the trash.
+func isDeleted(post *m.Post) bool {
+	return !post.DeletedAt.IsZero()
+}
+
+var _ PostRepository = (*PostDAO)(nil)
+var _ ApiKeyRepository = (*ApiKeyDAO)(nil)
@@ -0,0 +1,197 @@
+package dao
+
+import (
+	m "cloudbees/models"
+	"context"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the dao tracer of the current global TracerProvider.
+func tracer() trace.Tracer {
+	return otel.Tracer("cloudbees/dao")
+}
+
+// instrumentedPosts is a PostRepository recording how long the operations
+// of another take and, once bound to the context of a call with WithContext,
+// tracing them. The time of scans includes the time their fn takes.
+type instrumentedPosts struct {
+	posts PostRepository
+	// ctx holds the span the operations are traced under, nil for none.
+	ctx context.Context
+}
+
+var _ PostRepository = (*instrumentedPosts)(nil)
+
+// instrument returns posts recording how long its operations take.
+func instrument(posts PostRepository) PostRepository {
+	return &instrumentedPosts{posts: posts}
+}
+
+// WithContext returns posts tracing its operations as children of the span of
+// ctx, if posts is a blog of a BlogRepository, or else posts itself.
+func WithContext(ctx context.Context, posts PostRepository) PostRepository {
+	instrumented, ok := posts.(*instrumentedPosts)
+	if !ok {
+		return posts
+	}
+	return &instrumentedPosts{posts: instrumented.posts, ctx: ctx}
+}
+
+// observe runs the operation, recording how long it took and whether it
+// failed, and tracing it if the repository is bound to a context.
+func (r *instrumentedPosts) observe(operation string, op func() error, attributes ...attribute.KeyValue) error {
+	var span trace.Span
+	if r.ctx != nil {
+		_, span = tracer().Start(r.ctx, "dao."+operation, trace.WithAttributes(attributes...))
+	}
+	start := time.Now()
+	err := op()
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	operationSeconds.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+	if span != nil {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	return err
+}
+
+func postId(id uint64) attribute.KeyValue {
+	return attribute.Int64("post.id", int64(id))
+}
+
+func (r *instrumentedPosts) Create(post *m.Post) error {
+	return r.observe("create", func() error {
+		return r.posts.Create(post)
+	})
+}
+
+func (r *instrumentedPosts) Read(id uint64) (post *m.Post, err error) {
+	err = r.observe("read", func() error {
+		post, err = r.posts.Read(id)
+		return err
+	}, postId(id))
+	return post, err
+}
+
+func (r *instrumentedPosts) Update(post *m.Post) error {
+	return r.observe("update", func() error {
+		return r.posts.Update(post)
+	}, postId(post.PostId))
+}
+
+func (r *instrumentedPosts) Delete(id uint64) error {
+	return r.observe("delete", func() error {
+		return r.posts.Delete(id)
+	}, postId(id))
+}
+
+func (r *instrumentedPosts) Upsert(post *m.Post) error {
+	return r.observe("upsert", func() error {
+		return r.posts.Upsert(post)
+	}, postId(post.PostId))
+}
+
+func (r *instrumentedPosts) DeleteVersion(id uint64, version uint64) error {
+	return r.observe("delete_version", func() error {
+		return r.posts.DeleteVersion(id, version)
+	}, postId(id))
+}
+
+func (r *instrumentedPosts) Scan(afterId uint64, fn func(post *m.Post) bool) error {
+	return r.observe("scan", func() error {
+		return r.posts.Scan(afterId, fn)
+	})
+}
+
+func (r *instrumentedPosts) FindPosts(filter PostFilter, afterId uint64, fn func(post *m.Post) bool) error {
+	return r.observe("find_posts", func() error {
+		return r.posts.FindPosts(filter, afterId, fn)
+	})
+}
+
+func (r *instrumentedPosts) FindPostsDescending(filter PostFilter, beforeId uint64, fn func(post *m.Post) bool) error {
+	return r.observe("find_posts_descending", func() error {
+		return r.posts.FindPostsDescending(filter, beforeId, fn)
+	})
+}
+
+func (r *instrumentedPosts) FindPostsByPublicationDate(filter PostFilter, desc bool, after *PostCursor, fn func(post *m.Post) bool) error {
+	return r.observe("find_posts_by_publication_date", func() error {
+		return r.posts.FindPostsByPublicationDate(filter, desc, after, fn)
+	})
+}
+
+func (r *instrumentedPosts) Search(query string, offset int, limit int) (hits []SearchHit, total int, err error) {
+	err = r.observe("search", func() error {
+		hits, total, err = r.posts.Search(query, offset, limit)
+		return err
+	})
+	return hits, total, err
+}
+
+func (r *instrumentedPosts) ListRevisions(id uint64) (revisions []*m.PostRevision, err error) {
+	err = r.observe("list_revisions", func() error {
+		revisions, err = r.posts.ListRevisions(id)
+		return err
+	}, postId(id))
+	return revisions, err
+}
+
+func (r *instrumentedPosts) ReadRevision(id uint64, version uint64) (revision *m.PostRevision, err error) {
+	err = r.observe("read_revision", func() error {
+		revision, err = r.posts.ReadRevision(id, version)
+		return err
+	}, postId(id))
+	return revision, err
+}
+
+func (r *instrumentedPosts) Trash(id uint64, version uint64, deletedBy string, deletedAt time.Time) error {
+	return r.observe("trash", func() error {
+		return r.posts.Trash(id, version, deletedBy, deletedAt)
+	}, postId(id))
+}
+
+func (r *instrumentedPosts) Restore(id uint64) error {
+	return r.observe("restore", func() error {
+		return r.posts.Restore(id)
+	}, postId(id))
+}
+
+func (r *instrumentedPosts) Purge(id uint64) error {
+	return r.observe("purge", func() error {
+		return r.posts.Purge(id)
+	}, postId(id))
+}
+
+func (r *instrumentedPosts) ScanDeleted(afterId uint64, fn func(post *m.Post) bool) error {
+	return r.observe("scan_deleted", func() error {
+		return r.posts.ScanDeleted(afterId, fn)
+	})
+}
+
+func (r *instrumentedPosts) Count() (count int, err error) {
+	err = r.observe("count", func() error {
+		count, err = r.posts.Count()
+		return err
+	})
+	return count, err
+}
+
+// Close closes the repository it instruments, if it needs closing.
+func (r *instrumentedPosts) Close() error {
+	if closer, ok := r.posts.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package dao
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var lockWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "blog_dao_lock_wait_seconds",
+	Help:    "Time post repositories waited to take their lock, by lock mode.",
+	Buckets: []float64{.00001, .0001, .001, .01, .1, 1},
+}, []string{"mode"})
+
+var readLockWait = lockWaitSeconds.WithLabelValues("read")
+var writeLockWait = lockWaitSeconds.WithLabelValues("write")
+
+var operationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "blog_dao_operation_duration_seconds",
+	Help:    "Time post repository operations took, by operation and result.",
+	Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+}, []string{"operation", "result"})
+
+var postsDesc = prometheus.NewDesc("blog_posts", "Posts of each blog, leaving out those in the trash.", []string{"blog"}, nil)
+
+// Collectors returns the collectors of the storage metrics: the posts of
+// every blog of repo, and how long post repositories wait for their locks
+// and take for each operation.
+func Collectors(repo *BlogRepository) []prometheus.Collector {
+	return []prometheus.Collector{lockWaitSeconds, operationSeconds, postCollector{repo}}
+}
+
+// postCollector counts the posts of every blog when scraped.
+type postCollector struct {
+	repo *BlogRepository
+}
+
+func (c postCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- postsDesc
+}
+
+func (c postCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, blog := range c.repo.Blogs() {
+		posts, err := c.repo.Blog(blog)
+		if err != nil {
+			// Deleted since it was listed.
+			continue
+		}
+		count, err := posts.Count()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(postsDesc, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(postsDesc, prometheus.GaugeValue, float64(count), blog)
+	}
+}
@@ -0,0 +1,247 @@
+package dao
+
+import (
+	m "cloudbees/models"
+	"sort"
+	"time"
+)
+
+const (
+	// publicationDateLayout is the Go layout of the dd-mm-yyyy publication
+	// date format posts are written with.
+	publicationDateLayout = "02-01-2006"
+	// dateKeyLayout formats dates so they sort lexically in date order.
+	dateKeyLayout = "2006-01-02"
+)
+
+// PostFilter selects posts by author, tag and publication date. Zero fields
+// match every post.
+type PostFilter struct {
+	Author string
+	Tag    string
+	// PublishedFrom and PublishedTo bound the publication date, inclusive.
+	// Posts whose publication date can't be parsed never match a bound.
+	PublishedFrom time.Time
+	PublishedTo   time.Time
+}
+
+// PostCursor is the position of a post in publication date order.
+type PostCursor struct {
+	// PublicationDate is zero for posts whose publication date can't be
+	// parsed, which sort before all others.
+	PublicationDate time.Time
+	PostId          uint64
+}
+
+func dateKey(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateKeyLayout)
+}
+
+// publishedOn returns the date key of the post's publication date, or "" if
+// it can't be parsed.
+func publishedOn(post *m.Post) string {
+	published, err := time.Parse(publicationDateLayout, post.PublicationDate)
+	if err != nil {
+		return ""
+	}
+	return dateKey(published)
+}
+
+func (filter PostFilter) dateBounded() bool {
+	return !filter.PublishedFrom.IsZero() || !filter.PublishedTo.IsZero()
+}
+
+func (filter PostFilter) matches(post *m.Post) bool {
+	if filter.Author != "" && post.Author != filter.Author {
+		return false
+	}
+	if filter.Tag != "" && !hasTag(post, filter.Tag) {
+		return false
+	}
+	if !filter.dateBounded() {
+		return true
+	}
+	date := publishedOn(post)
+	if date == "" {
+		return false
+	}
+	if !filter.PublishedFrom.IsZero() && date < dateKey(filter.PublishedFrom) {
+		return false
+	}
+	if !filter.PublishedTo.IsZero() && date > dateKey(filter.PublishedTo) {
+		return false
+	}
+	return true
+}
+
+func hasTag(post *m.Post, tag string) bool {
+	for _, t := range post.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// datedId is an entry of the publication date index.
+type datedId struct {
+	date string
+	id   uint64
+}
+
+func (a datedId) less(b datedId) bool {
+	if a.date != b.date {
+		return a.date < b.date
+	}
+	return a.id < b.id
+}
+
+// postIndexes are the secondary indexes of the posts that are not in the
+// trash. Callers synchronize access.
+type postIndexes struct {
+	// byAuthor and byTag map to ids in ascending order.
+	byAuthor map[string][]uint64
+	byTag    map[string][]uint64
+	// byDate is ordered by publication date, then id.
+	byDate []datedId
+}
+
+func newPostIndexes() *postIndexes {
+	return &postIndexes{
+		byAuthor: make(map[string][]uint64),
+		byTag:    make(map[string][]uint64),
+	}
+}
+
+func (indexes *postIndexes) add(post *m.Post) {
+	indexes.byAuthor[post.Author] = insertSorted(indexes.byAuthor[post.Author], post.PostId)
+	for _, tag := range post.Tags {
+		indexes.byTag[tag] = insertSorted(indexes.byTag[tag], post.PostId)
+	}
+	entry := datedId{date: publishedOn(post), id: post.PostId}
+	i := sort.Search(len(indexes.byDate), func(i int) bool { return !indexes.byDate[i].less(entry) })
+	indexes.byDate = append(indexes.byDate, datedId{})
+	copy(indexes.byDate[i+1:], indexes.byDate[i:])
+	indexes.byDate[i] = entry
+}
+
+func (indexes *postIndexes) remove(post *m.Post) {
+	removeFromIndex(indexes.byAuthor, post.Author, post.PostId)
+	for _, tag := range post.Tags {
+		removeFromIndex(indexes.byTag, tag, post.PostId)
+	}
+	entry := datedId{date: publishedOn(post), id: post.PostId}
+	i := sort.Search(len(indexes.byDate), func(i int) bool { return !indexes.byDate[i].less(entry) })
+	if i < len(indexes.byDate) && indexes.byDate[i] == entry {
+		indexes.byDate = append(indexes.byDate[:i], indexes.byDate[i+1:]...)
+	}
+}
+
+func removeFromIndex(index map[string][]uint64, key string, id uint64) {
+	ids := removeSorted(index[key], id)
+	if len(ids) == 0 {
+		delete(index, key)
+		return
+	}
+	index[key] = ids
+}
+
+// dateRange returns the entries of byDate within the filter's publication
+// date bounds.
+func (indexes *postIndexes) dateRange(filter PostFilter) []datedId {
+	if !filter.dateBounded() {
+		return indexes.byDate
+	}
+	// Unparseable dates have the empty key and never match a bound.
+	from := "\x00"
+	if !filter.PublishedFrom.IsZero() {
+		from = dateKey(filter.PublishedFrom)
+	}
+	start := sort.Search(len(indexes.byDate), func(i int) bool { return indexes.byDate[i].date >= from })
+	end := len(indexes.byDate)
+	if !filter.PublishedTo.IsZero() {
+		to := dateKey(filter.PublishedTo)
+		end = sort.Search(len(indexes.byDate), func(i int) bool { return indexes.byDate[i].date > to })
+	}
+	if end < start {
+		end = start
+	}
+	return indexes.byDate[start:end]
+}
+
+// candidates returns the ids, in ascending order, of the smallest index entry
+// the filter selects on, which holds every post matching the filter. It
+// returns false when the filter selects on no index.
+func (indexes *postIndexes) candidates(filter PostFilter) ([]uint64, bool) {
+	var best []uint64
+	found := false
+	consider := func(ids []uint64) {
+		if !found || len(ids) < len(best) {
+			best, found = ids, true
+		}
+	}
+	if filter.Author != "" {
+		consider(indexes.byAuthor[filter.Author])
+	}
+	if filter.Tag != "" {
+		consider(indexes.byTag[filter.Tag])
+	}
+	if filter.dateBounded() {
+		dated := indexes.dateRange(filter)
+		if !found || len(dated) < len(best) {
+			ids := make([]uint64, 0, len(dated))
+			for _, entry := range dated {
+				ids = append(ids, entry.id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			consider(ids)
+		}
+	}
+	return best, found
+}
+
+// byPublicationDate returns entries, in publication date order, holding every
+// post matching the filter.
+func (indexes *postIndexes) byPublicationDate(filter PostFilter, lookup func(id uint64) *m.Post) []datedId {
+	dated := indexes.dateRange(filter)
+	var ids []uint64
+	found := false
+	if filter.Author != "" {
+		ids, found = indexes.byAuthor[filter.Author], true
+	}
+	if filter.Tag != "" && (!found || len(indexes.byTag[filter.Tag]) < len(ids)) {
+		ids, found = indexes.byTag[filter.Tag], true
+	}
+	if !found || len(ids) >= len(dated) {
+		return dated
+	}
+	// Sorting the few posts of an author or tag beats walking the dates.
+	entries := make([]datedId, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, datedId{date: publishedOn(lookup(id)), id: id})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].less(entries[j]) })
+	return entries
+}
+
+func insertSorted(ids []uint64, id uint64) []uint64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i < len(ids) && ids[i] == id {
+		return ids
+	}
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	return ids
+}
+
+func removeSorted(ids []uint64, id uint64) []uint64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i < len(ids) && ids[i] == id {
+		return append(ids[:i], ids[i+1:]...)
+	}
+	return ids
+}
@@ -4,64 +4,417 @@ package dao
 import (
 	e "cloudbees/errors"
 	m "cloudbees/models"
+	"sort"
 	"sync"
+	"time"
 )
 
 type PostDAO struct {
-	posts map[uint64]*m.Post
-	mu    sync.Mutex
+	posts     map[uint64]*m.Post
+	revisions map[uint64][]*m.PostRevision
+	// ids holds the keys of posts in ascending order so scans are stable.
+	ids []uint64
+	// lastId is the highest id up to maxGeneratedId ever created, the base of
+	// the id sequence.
+	lastId uint64
+	// mu is held for reading by lookups, which run concurrently, and for
+	// writing by mutations.
+	mu sync.RWMutex
+	// wal, when set, durably records every mutation before it is applied.
+	wal   *writeAheadLog
+	index *searchIndex
+	// indexes are kept up to date with posts as every record is applied.
+	indexes *postIndexes
 }
 
-var instance *PostDAO
-var once sync.Once
-
+// NewPostDAO returns an empty in-memory PostDAO, independent of every other.
 func NewPostDAO() *PostDAO {
-	once.Do(func() {
-		instance = &PostDAO{
-			posts: make(map[uint64]*m.Post),
-			mu:    sync.Mutex{},
+	return &PostDAO{
+		posts:     make(map[uint64]*m.Post),
+		revisions: make(map[uint64][]*m.PostRevision),
+		index:     newSearchIndex(),
+		indexes:   newPostIndexes(),
+	}
+}
+
+// NewFilePostDAO returns a PostDAO that persists every mutation to the
+// write-ahead log at path, rebuilding its posts from the log on startup.
+func NewFilePostDAO(path string) (*PostDAO, error) {
+	dao := NewPostDAO()
+	wal, err := openWriteAheadLog(path, dao.apply)
+	if err != nil {
+		return nil, err
+	}
+	dao.wal = wal
+	return dao, nil
+}
+
+// lock and rlock acquire mu for writing and reading, recording how long
+// they waited.
+func (dao *PostDAO) lock() {
+	start := time.Now()
+	dao.mu.Lock()
+	writeLockWait.Observe(time.Since(start).Seconds())
+}
+
+func (dao *PostDAO) rlock() {
+	start := time.Now()
+	dao.mu.RLock()
+	readLockWait.Observe(time.Since(start).Seconds())
+}
+
+// Close releases the write-ahead log, if any. The DAO must not be used afterwards.
+func (dao *PostDAO) Close() error {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	if dao.wal == nil {
+		return nil
+	}
+	return dao.wal.close()
+}
+
+// commit logs the record, when the DAO is durable, and then applies it.
+// Callers must hold dao.mu.
+func (dao *PostDAO) commit(record walRecord) error {
+	if dao.wal != nil {
+		if err := dao.wal.append(record); err != nil {
+			return err
 		}
-	})
-	return instance
+	}
+	dao.apply(record)
+	return nil
+}
+
+func (dao *PostDAO) apply(record walRecord) {
+	switch record.Op {
+	case walCreate, walUpdate:
+		if record.Post.Version == 0 {
+			// Logged before posts were versioned.
+			record.Post.Version = 1
+		}
+		previous, exists := dao.posts[record.PostId]
+		if !exists {
+			dao.insertId(record.PostId)
+		} else if !isDeleted(previous) {
+			dao.indexes.remove(previous)
+		}
+		dao.revisions[record.PostId] = append(dao.revisions[record.PostId], newRevision(previous, record.Post))
+		if record.PostId > dao.lastId && record.PostId <= maxGeneratedId {
+			dao.lastId = record.PostId
+		}
+		dao.posts[record.PostId] = record.Post
+		dao.index.add(record.Post)
+		dao.indexes.add(record.Post)
+	case walDelete:
+		if stored, exists := dao.posts[record.PostId]; exists {
+			if !isDeleted(stored) {
+				dao.indexes.remove(stored)
+			}
+			delete(dao.posts, record.PostId)
+			delete(dao.revisions, record.PostId)
+			dao.removeId(record.PostId)
+			dao.index.remove(record.PostId)
+		}
+	case walTrash, walRestore:
+		if stored, exists := dao.posts[record.PostId]; exists {
+			dao.posts[record.PostId] = record.Post
+			if record.Op == walTrash {
+				dao.index.remove(record.PostId)
+				dao.indexes.remove(stored)
+			} else {
+				dao.index.add(record.Post)
+				dao.indexes.add(record.Post)
+			}
+		}
+	}
 }
 
 func (dao *PostDAO) Create(post *m.Post) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	if post.PostId == 0 {
+		if dao.lastId >= maxGeneratedId {
+			return e.IdSpaceExhaustedError
+		}
+		post.PostId = dao.lastId + 1
+		if err := dao.commitVersion(walCreate, post, 1); err != nil {
+			post.PostId = 0
+			return err
+		}
+		return nil
+	}
+	if _, exists := dao.posts[post.PostId]; exists {
+		return e.EntityAlreadyExistsError
+	}
+	return dao.commitVersion(walCreate, post, 1)
+}
 
-	dao.mu.Lock()
+func (dao *PostDAO) Upsert(post *m.Post) error {
+	dao.lock()
 	defer dao.mu.Unlock()
-	print(dao.posts[post.PostId])
-	dao.posts[post.PostId] = post
+	stored, exists := dao.posts[post.PostId]
+	if exists && isDeleted(stored) {
+		return e.EntityAlreadyExistsError
+	}
+	if post.Version != 0 && (!exists || post.Version != stored.Version) {
+		return e.VersionConflictError
+	}
+	if !exists {
+		return dao.commitVersion(walCreate, post, 1)
+	}
+	return dao.commitVersion(walUpdate, post, stored.Version+1)
+}
+
+// commitVersion commits the post at the given version, setting it on the post
+// once it is committed. Callers must hold dao.mu.
+func (dao *PostDAO) commitVersion(op walOp, post *m.Post, version uint64) error {
+	previous := post.Version
+	post.Version = version
+	if err := dao.commit(walRecord{Op: op, PostId: post.PostId, Post: copyPost(post)}); err != nil {
+		post.Version = previous
+		return err
+	}
 	return nil
 }
 
 func (dao *PostDAO) Read(id uint64) (*m.Post, error) {
-	dao.mu.Lock()
-	defer dao.mu.Unlock()
+	dao.rlock()
+	defer dao.mu.RUnlock()
 	post, exists := dao.posts[id]
-	if !exists {
+	if !exists || isDeleted(post) {
 		return nil, e.EnitityNotFoundError
 	}
-	return post, nil
+	return copyPost(post), nil
 }
 
 func (dao *PostDAO) Update(post *m.Post) error {
-	dao.mu.Lock()
+	dao.lock()
 	defer dao.mu.Unlock()
-	_, exists := dao.posts[post.PostId]
-	if !exists {
+	stored, exists := dao.posts[post.PostId]
+	if !exists || isDeleted(stored) {
 		return e.EnitityNotFoundError
 	}
-	dao.posts[post.PostId] = post
-	return nil
+	if post.Version != 0 && post.Version != stored.Version {
+		return e.VersionConflictError
+	}
+	return dao.commitVersion(walUpdate, post, stored.Version+1)
 }
 
 func (dao *PostDAO) Delete(id uint64) error {
-	dao.mu.Lock()
+	return dao.DeleteVersion(id, 0)
+}
+
+func (dao *PostDAO) DeleteVersion(id uint64, version uint64) error {
+	dao.lock()
 	defer dao.mu.Unlock()
-	_, exists := dao.posts[id]
+	stored, exists := dao.posts[id]
 	if !exists {
 		return e.EnitityNotFoundError
 	}
-	delete(dao.posts, id)
+	if version != 0 && version != stored.Version {
+		return e.VersionConflictError
+	}
+	return dao.commit(walRecord{Op: walDelete, PostId: id})
+}
+
+func (dao *PostDAO) Trash(id uint64, version uint64, deletedBy string, deletedAt time.Time) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	stored, exists := dao.posts[id]
+	if !exists || isDeleted(stored) {
+		return e.EnitityNotFoundError
+	}
+	if version != 0 && version != stored.Version {
+		return e.VersionConflictError
+	}
+	trashed := copyPost(stored)
+	trashed.DeletedAt = deletedAt
+	trashed.DeletedBy = deletedBy
+	return dao.commit(walRecord{Op: walTrash, PostId: id, Post: trashed})
+}
+
+func (dao *PostDAO) Restore(id uint64) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	stored, exists := dao.posts[id]
+	if !exists || !isDeleted(stored) {
+		return e.EnitityNotFoundError
+	}
+	restored := copyPost(stored)
+	restored.DeletedAt = time.Time{}
+	restored.DeletedBy = ""
+	return dao.commit(walRecord{Op: walRestore, PostId: id, Post: restored})
+}
+
+func (dao *PostDAO) Purge(id uint64) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	stored, exists := dao.posts[id]
+	if !exists || !isDeleted(stored) {
+		return e.EnitityNotFoundError
+	}
+	return dao.commit(walRecord{Op: walDelete, PostId: id})
+}
+
+// Scan calls fn for every post with an id greater than afterId in ascending
+// id order, stopping as soon as fn returns false. Pass 0 to scan from the
+// beginning. fn runs with the DAO read-locked and must not call back into it.
+func (dao *PostDAO) Scan(afterId uint64, fn func(post *m.Post) bool) error {
+	return dao.scan(afterId, false, fn)
+}
+
+func (dao *PostDAO) ScanDeleted(afterId uint64, fn func(post *m.Post) bool) error {
+	return dao.scan(afterId, true, fn)
+}
+
+// scan calls fn for the posts after afterId that are in the trash, or not,
+// as deleted says.
+func (dao *PostDAO) scan(afterId uint64, deleted bool, fn func(post *m.Post) bool) error {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	start := sort.Search(len(dao.ids), func(i int) bool { return dao.ids[i] > afterId })
+	for _, id := range dao.ids[start:] {
+		post := dao.posts[id]
+		if isDeleted(post) != deleted {
+			continue
+		}
+		if !fn(copyPost(post)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (dao *PostDAO) Count() (int, error) {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	count := 0
+	for _, post := range dao.posts {
+		if !isDeleted(post) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (dao *PostDAO) FindPosts(filter PostFilter, afterId uint64, fn func(post *m.Post) bool) error {
+	return dao.find(filter, afterId, false, fn)
+}
+
+func (dao *PostDAO) FindPostsDescending(filter PostFilter, beforeId uint64, fn func(post *m.Post) bool) error {
+	return dao.find(filter, beforeId, true, fn)
+}
+
+// find calls fn for the posts matching filter after the cursor id in
+// ascending id order, or before it in descending order if desc is set.
+func (dao *PostDAO) find(filter PostFilter, cursor uint64, desc bool, fn func(post *m.Post) bool) error {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	ids, found := dao.indexes.candidates(filter)
+	if !found {
+		ids = dao.ids
+	}
+	start, end := 0, len(ids)
+	switch {
+	case !desc:
+		start = sort.Search(len(ids), func(i int) bool { return ids[i] > cursor })
+	case cursor != 0:
+		end = sort.Search(len(ids), func(i int) bool { return ids[i] >= cursor })
+	}
+	for i := 0; i < end-start; i++ {
+		id := ids[start+i]
+		if desc {
+			id = ids[end-1-i]
+		}
+		post := dao.posts[id]
+		if isDeleted(post) || !filter.matches(post) {
+			continue
+		}
+		if !fn(copyPost(post)) {
+			break
+		}
+	}
 	return nil
 }
+
+func (dao *PostDAO) FindPostsByPublicationDate(filter PostFilter, desc bool, after *PostCursor, fn func(post *m.Post) bool) error {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	entries := dao.indexes.byPublicationDate(filter, func(id uint64) *m.Post { return dao.posts[id] })
+	start, end := 0, len(entries)
+	if after != nil {
+		cursor := datedId{date: dateKey(after.PublicationDate), id: after.PostId}
+		if desc {
+			end = sort.Search(len(entries), func(i int) bool { return !entries[i].less(cursor) })
+		} else {
+			start = sort.Search(len(entries), func(i int) bool { return cursor.less(entries[i]) })
+		}
+	}
+	for i := 0; i < end-start; i++ {
+		entry := entries[start+i]
+		if desc {
+			entry = entries[end-1-i]
+		}
+		post := dao.posts[entry.id]
+		if !filter.matches(post) {
+			continue
+		}
+		if !fn(copyPost(post)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (dao *PostDAO) Search(query string, offset int, limit int) ([]SearchHit, int, error) {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	return dao.index.search(query, offset, limit, func(id uint64) (*m.Post, error) {
+		if post, exists := dao.posts[id]; exists {
+			return copyPost(post), nil
+		}
+		return nil, nil
+	})
+}
+
+func (dao *PostDAO) ListRevisions(id uint64) ([]*m.PostRevision, error) {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	revisions, exists := dao.revisions[id]
+	if !exists || dao.inTrash(id) {
+		return nil, e.EnitityNotFoundError
+	}
+	copied := make([]*m.PostRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		copied = append(copied, copyRevision(revision))
+	}
+	return copied, nil
+}
+
+func (dao *PostDAO) ReadRevision(id uint64, version uint64) (*m.PostRevision, error) {
+	dao.rlock()
+	defer dao.mu.RUnlock()
+	if dao.inTrash(id) {
+		return nil, e.EnitityNotFoundError
+	}
+	for _, revision := range dao.revisions[id] {
+		if revision.Post.Version == version {
+			return copyRevision(revision), nil
+		}
+	}
+	return nil, e.EnitityNotFoundError
+}
+
+// inTrash reports whether the post is in the trash. Callers hold dao.mu.
+func (dao *PostDAO) inTrash(id uint64) bool {
+	post, exists := dao.posts[id]
+	return exists && isDeleted(post)
+}
+
+func (dao *PostDAO) insertId(id uint64) {
+	dao.ids = insertSorted(dao.ids, id)
+}
+
+func (dao *PostDAO) removeId(id uint64) {
+	dao.ids = removeSorted(dao.ids, id)
+}
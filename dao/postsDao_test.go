@@ -0,0 +1,92 @@
+package dao
+
+import (
+	m "cloudbees/models"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkPosts is how many posts the benchmarks read and update.
+const benchmarkPosts = 1024
+
+// postStore is the part of a repository the benchmarks exercise.
+type postStore interface {
+	Read(id uint64) (*m.Post, error)
+	Update(post *m.Post) error
+}
+
+// serializedStore guards every call with one mutex, as PostDAO did before
+// reads could run concurrently. It is the baseline of the benchmarks.
+type serializedStore struct {
+	mu  sync.Mutex
+	dao *PostDAO
+}
+
+func (s *serializedStore) Read(id uint64) (*m.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dao.Read(id)
+}
+
+func (s *serializedStore) Update(post *m.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dao.Update(post)
+}
+
+// BenchmarkPostDAOMixedLoad measures operations per second when every
+// goroutine reads posts and every writeEvery-th operation updates one
+// instead, for PostDAO and for the serialized baseline.
+func BenchmarkPostDAOMixedLoad(b *testing.B) {
+	for _, writeEvery := range []int{0, 100, 10} {
+		for _, parallelism := range []int{1, 16, 64} {
+			for _, store := range []string{"rwmutex", "mutex"} {
+				load := "reads"
+				if writeEvery > 0 {
+					load = fmt.Sprintf("1in%dwrites", writeEvery)
+				}
+				name := fmt.Sprintf("%s/goroutines=%d/%s", load, parallelism*runtime.GOMAXPROCS(0), store)
+				b.Run(name, func(b *testing.B) {
+					benchmarkMixedLoad(b, newBenchmarkStore(b, store), writeEvery, parallelism)
+				})
+			}
+		}
+	}
+}
+
+func newBenchmarkStore(b *testing.B, kind string) postStore {
+	dao := NewPostDAO()
+	for id := uint64(1); id <= benchmarkPosts; id++ {
+		if err := dao.Create(newTestPost(id, "Title")); err != nil {
+			b.Fatalf("failed to create post: %v", err)
+		}
+	}
+	if kind == "mutex" {
+		return &serializedStore{dao: dao}
+	}
+	return dao
+}
+
+func benchmarkMixedLoad(b *testing.B, store postStore, writeEvery int, parallelism int) {
+	var next uint64
+	b.SetParallelism(parallelism)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := atomic.AddUint64(&next, 1)
+			id := n%benchmarkPosts + 1
+			if writeEvery > 0 && n%uint64(writeEvery) == 0 {
+				if err := store.Update(newTestPost(id, "Updated Title")); err != nil {
+					b.Errorf("failed to update post: %v", err)
+				}
+				continue
+			}
+			if _, err := store.Read(id); err != nil {
+				b.Errorf("failed to read post: %v", err)
+			}
+		}
+	})
+}
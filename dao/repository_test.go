@@ -0,0 +1,613 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// repositories returns a fresh instance of every PostRepository implementation.
+func repositories(t *testing.T) map[string]PostRepository {
+	t.Helper()
+	file, err := NewFilePostDAO(filepath.Join(t.TempDir(), "posts.wal"))
+	if err != nil {
+		t.Fatalf("failed to open file dao: %v", err)
+	}
+	sqlite, err := NewSQLitePostDAO(filepath.Join(t.TempDir(), "posts.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite dao: %v", err)
+	}
+	t.Cleanup(func() {
+		file.Close()
+		sqlite.Close()
+	})
+	return map[string]PostRepository{
+		"memory":       NewPostDAO(),
+		"file":         file,
+		"sqlite":       sqlite,
+		"instrumented": instrument(NewPostDAO()),
+	}
+}
+
+func TestPostRepositoryBehavior(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.Read(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v reading missing post, got %v", e.EnitityNotFoundError, err)
+			}
+			if err := repo.Update(newTestPost(1, "Title")); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v updating missing post, got %v", e.EnitityNotFoundError, err)
+			}
+			if err := repo.Delete(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v deleting missing post, got %v", e.EnitityNotFoundError, err)
+			}
+
+			for _, id := range []uint64{3, 1, math.MaxUint64, 2} {
+				if err := repo.Create(newTestPost(id, "Title")); err != nil {
+					t.Fatalf("failed to create post %d: %v", id, err)
+				}
+			}
+			if err := repo.Create(newTestPost(1, "Duplicate Title")); err != e.EntityAlreadyExistsError {
+				t.Fatalf("expected %v creating duplicate post, got %v", e.EntityAlreadyExistsError, err)
+			}
+			upserted := newTestPost(4, "Upserted Title")
+			if err := repo.Upsert(upserted); err != nil || upserted.Version != 1 {
+				t.Fatalf("expected upsert to create post at version 1, got %d, %v", upserted.Version, err)
+			}
+			upserted = newTestPost(4, "Replaced Title")
+			if err := repo.Upsert(upserted); err != nil || upserted.Version != 2 {
+				t.Fatalf("expected upsert to replace post at version 2, got %d, %v", upserted.Version, err)
+			}
+			if err := repo.Delete(4); err != nil {
+				t.Fatalf("failed to delete post: %v", err)
+			}
+			updated := newTestPost(2, "Updated Title")
+			updated.Tags = []string{"b", "a"}
+			if err := repo.Update(updated); err != nil {
+				t.Fatalf("failed to update post: %v", err)
+			}
+			post, err := repo.Read(2)
+			if err != nil {
+				t.Fatalf("failed to read post: %v", err)
+			}
+			if post.Title != "Updated Title" || fmt.Sprint(post.Tags) != "[b a]" {
+				t.Fatalf("unexpected post after update: %+v", post)
+			}
+			if post.Version != 2 || updated.Version != 2 {
+				t.Fatalf("expected version 2 after update, got %d and %d", post.Version, updated.Version)
+			}
+			stale := newTestPost(2, "Stale Title")
+			stale.Version = 1
+			if err := repo.Update(stale); err != e.VersionConflictError {
+				t.Fatalf("expected %v updating stale version, got %v", e.VersionConflictError, err)
+			}
+			if err := repo.DeleteVersion(2, 1); err != e.VersionConflictError {
+				t.Fatalf("expected %v deleting stale version, got %v", e.VersionConflictError, err)
+			}
+			if err := repo.Delete(3); err != nil {
+				t.Fatalf("failed to delete post: %v", err)
+			}
+
+			var ids []uint64
+			repo.Scan(0, func(post *m.Post) bool {
+				ids = append(ids, post.PostId)
+				return true
+			})
+			if fmt.Sprint(ids) != fmt.Sprint([]uint64{1, 2, math.MaxUint64}) {
+				t.Fatalf("unexpected scan order %v", ids)
+			}
+
+			ids = nil
+			repo.Scan(1, func(post *m.Post) bool {
+				ids = append(ids, post.PostId)
+				return false
+			})
+			if fmt.Sprint(ids) != "[2]" {
+				t.Fatalf("expected scan to resume after 1 and stop early, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestPostRepositoryCount(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for id := uint64(1); id <= 3; id++ {
+				if err := repo.Create(newTestPost(id, "Post")); err != nil {
+					t.Fatalf("failed to create post: %v", err)
+				}
+			}
+			if err := repo.Trash(2, 0, "alice", time.Now()); err != nil {
+				t.Fatalf("failed to trash post: %v", err)
+			}
+			if count, err := repo.Count(); err != nil || count != 2 {
+				t.Fatalf("expected 2 posts outside the trash, got %d, %v", count, err)
+			}
+		})
+	}
+}
+
+func TestPostRepositoryGeneratedIds(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			first := newTestPost(0, "Title")
+			if err := repo.Create(first); err != nil || first.PostId != 1 {
+				t.Fatalf("expected first generated id 1, got %d, %v", first.PostId, err)
+			}
+			if err := repo.Create(newTestPost(10, "Title")); err != nil {
+				t.Fatalf("failed to create post: %v", err)
+			}
+			if err := repo.Delete(10); err != nil {
+				t.Fatalf("failed to delete post: %v", err)
+			}
+			next := newTestPost(0, "Title")
+			if err := repo.Create(next); err != nil || next.PostId != 11 {
+				t.Fatalf("expected generated id 11 past deleted client id, got %d, %v", next.PostId, err)
+			}
+		})
+	}
+}
+
+func TestPostRepositoryGeneratedIdsAfterHighClientId(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []uint64{math.MaxUint64, maxGeneratedId + 1} {
+				if err := repo.Create(newTestPost(id, "Title")); err != nil {
+					t.Fatalf("failed to create post %d: %v", id, err)
+				}
+			}
+			next := newTestPost(0, "Title")
+			if err := repo.Create(next); err != nil || next.PostId != 1 {
+				t.Fatalf("expected generated id 1 after high client ids, got %d, %v", next.PostId, err)
+			}
+		})
+	}
+}
+
+func TestFilePostDAOGeneratedIdsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(0, "Title"))
+	dao.Create(newTestPost(0, "Title"))
+	dao.Delete(2)
+	dao.Close()
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	post := newTestPost(0, "Title")
+	if err := dao.Create(post); err != nil || post.PostId != 3 {
+		t.Fatalf("expected generated id 3 after restart, got %d, %v", post.PostId, err)
+	}
+}
+
+func TestPostRepositorySearch(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			first := newTestPost(1, "Searching posts")
+			first.Content = "An inverted index finds posts by words."
+			second := newTestPost(2, "Indexes")
+			second.Content = "Secondary indexes speed up queries."
+			repo.Create(first)
+			repo.Create(second)
+
+			hits, total, err := repo.Search("index*", 0, 1)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+			if total != 2 || len(hits) != 1 {
+				t.Fatalf("expected 1 of 2 hits, got %d of %d", len(hits), total)
+			}
+
+			second.Content = "Nothing to find here."
+			second.Title = "Renamed"
+			if err := repo.Update(second); err != nil {
+				t.Fatalf("failed to update post: %v", err)
+			}
+			repo.Delete(1)
+			if _, total, _ := repo.Search("index*", 0, 10); total != 0 {
+				t.Fatalf("expected updated and deleted posts to be unindexed, got %d hits", total)
+			}
+		})
+	}
+}
+
+func TestSQLitePostDAORebuildsSearchIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.db")
+	dao, err := NewSQLitePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(1, "Persistent search"))
+	dao.Close()
+
+	dao, err = NewSQLitePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	if _, total, _ := dao.Search("persistent", 0, 10); total != 1 {
+		t.Fatalf("expected search index to be rebuilt on startup, got %d hits", total)
+	}
+}
+
+func TestPostRepositoryRevisions(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			post := newTestPost(1, "First Title")
+			post.UpdatedBy = "alice"
+			repo.Create(post)
+			updated := newTestPost(1, "Second Title")
+			updated.Tags = []string{"revised"}
+			updated.UpdatedBy = "bob"
+			if err := repo.Update(updated); err != nil {
+				t.Fatalf("failed to update post: %v", err)
+			}
+
+			revisions, err := repo.ListRevisions(1)
+			if err != nil || len(revisions) != 2 {
+				t.Fatalf("expected 2 revisions, got %d, %v", len(revisions), err)
+			}
+			if revisions[0].Post.Version != 1 || revisions[0].Post.Title != "First Title" || revisions[0].Post.UpdatedBy != "alice" {
+				t.Fatalf("unexpected first revision %+v", revisions[0])
+			}
+			if fmt.Sprint(revisions[1].ChangedFields) != "[title tags]" || revisions[1].Post.UpdatedBy != "bob" {
+				t.Fatalf("unexpected second revision %+v", revisions[1])
+			}
+
+			revision, err := repo.ReadRevision(1, 2)
+			if err != nil || revision.Post.Title != "Second Title" || fmt.Sprint(revision.Post.Tags) != "[revised]" {
+				t.Fatalf("unexpected revision 2: %+v, %v", revision, err)
+			}
+			if _, err := repo.ReadRevision(1, 3); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v reading missing revision, got %v", e.EnitityNotFoundError, err)
+			}
+
+			repo.Delete(1)
+			if _, err := repo.ListRevisions(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected revisions to be deleted with the post, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFilePostDAORevisionsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(1, "First Title"))
+	dao.Update(newTestPost(1, "Second Title"))
+	dao.Close()
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	revisions, err := dao.ListRevisions(1)
+	if err != nil || len(revisions) != 2 || revisions[0].Post.Title != "First Title" {
+		t.Fatalf("expected revisions to be replayed from the log, got %v, %v", revisions, err)
+	}
+}
+
+func TestPostRepositoryTrash(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo.Create(newTestPost(1, "Trashed Title"))
+			repo.Create(newTestPost(2, "Kept Title"))
+			deletedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := repo.Trash(1, 2, "alice", deletedAt); err != e.VersionConflictError {
+				t.Fatalf("expected %v trashing stale version, got %v", e.VersionConflictError, err)
+			}
+			if err := repo.Trash(1, 1, "alice", deletedAt); err != nil {
+				t.Fatalf("failed to trash post: %v", err)
+			}
+			if err := repo.Trash(1, 0, "alice", deletedAt); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v trashing post twice, got %v", e.EnitityNotFoundError, err)
+			}
+
+			if _, err := repo.Read(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v reading trashed post, got %v", e.EnitityNotFoundError, err)
+			}
+			if err := repo.Update(newTestPost(1, "Title")); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v updating trashed post, got %v", e.EnitityNotFoundError, err)
+			}
+			if err := repo.Create(newTestPost(1, "Title")); err != e.EntityAlreadyExistsError {
+				t.Fatalf("expected %v reusing trashed id, got %v", e.EntityAlreadyExistsError, err)
+			}
+			if err := repo.Upsert(newTestPost(1, "Title")); err != e.EntityAlreadyExistsError {
+				t.Fatalf("expected %v upserting trashed post, got %v", e.EntityAlreadyExistsError, err)
+			}
+			if _, total, _ := repo.Search("trashed", 0, 10); total != 0 {
+				t.Fatalf("expected trashed post to be unindexed, got %d hits", total)
+			}
+			if _, err := repo.ListRevisions(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v listing revisions of trashed post, got %v", e.EnitityNotFoundError, err)
+			}
+			if _, err := repo.ReadRevision(1, 1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v reading revision of trashed post, got %v", e.EnitityNotFoundError, err)
+			}
+
+			var live, deleted []*m.Post
+			repo.Scan(0, func(post *m.Post) bool {
+				live = append(live, post)
+				return true
+			})
+			repo.ScanDeleted(0, func(post *m.Post) bool {
+				deleted = append(deleted, post)
+				return true
+			})
+			if len(live) != 1 || live[0].PostId != 2 {
+				t.Fatalf("expected only post 2 to be scanned, got %v", live)
+			}
+			if len(deleted) != 1 || deleted[0].DeletedBy != "alice" || !deleted[0].DeletedAt.Equal(deletedAt) {
+				t.Fatalf("expected post 1 in the trash, got %v", deleted)
+			}
+
+			if err := repo.Purge(2); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v purging a post not in the trash, got %v", e.EnitityNotFoundError, err)
+			}
+			if err := repo.Restore(1); err != nil {
+				t.Fatalf("failed to restore post: %v", err)
+			}
+			post, err := repo.Read(1)
+			if err != nil || !post.DeletedAt.IsZero() || post.DeletedBy != "" {
+				t.Fatalf("expected restored post, got %+v, %v", post, err)
+			}
+			if revision, err := repo.ReadRevision(1, 1); err != nil || revision.Post.Title != "Trashed Title" {
+				t.Fatalf("expected the revisions of the restored post, got %+v, %v", revision, err)
+			}
+			if _, total, _ := repo.Search("trashed", 0, 10); total != 1 {
+				t.Fatalf("expected restored post to be indexed, got %d hits", total)
+			}
+			if err := repo.Restore(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v restoring a post not in the trash, got %v", e.EnitityNotFoundError, err)
+			}
+
+			repo.Trash(1, 0, "alice", deletedAt)
+			if err := repo.Purge(1); err != nil {
+				t.Fatalf("failed to purge post: %v", err)
+			}
+			if err := repo.Restore(1); err != e.EnitityNotFoundError {
+				t.Fatalf("expected %v restoring a purged post, got %v", e.EnitityNotFoundError, err)
+			}
+		})
+	}
+}
+
+func TestFilePostDAOTrashPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(1, "Title"))
+	dao.Create(newTestPost(2, "Title"))
+	dao.Trash(1, 0, "alice", time.Now())
+	dao.Trash(2, 0, "alice", time.Now())
+	dao.Restore(2)
+	dao.Close()
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	if _, err := dao.Read(1); err != e.EnitityNotFoundError {
+		t.Fatalf("expected post 1 to stay in the trash, got %v", err)
+	}
+	if _, err := dao.Read(2); err != nil {
+		t.Fatalf("expected post 2 to stay restored, got %v", err)
+	}
+}
+
+func TestPostRepositoryFindPosts(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, p := range []struct {
+				id     uint64
+				author string
+				date   string
+				tags   []string
+			}{
+				{1, "alice", "03-01-2024", []string{"go", "grpc"}},
+				{2, "bob", "01-01-2024", []string{"go"}},
+				{3, "alice", "02-01-2024", []string{"rust"}},
+				{4, "alice", "01-01-2024", []string{"go"}},
+				{math.MaxUint64, "alice", "not a date", []string{"go"}},
+			} {
+				post := newTestPost(p.id, "Title")
+				post.Author, post.PublicationDate, post.Tags = p.author, p.date, p.tags
+				if err := repo.Create(post); err != nil {
+					t.Fatalf("failed to create post %d: %v", p.id, err)
+				}
+			}
+			moved := newTestPost(3, "Title")
+			moved.Author, moved.PublicationDate, moved.Tags = "carol", "05-01-2024", []string{"go"}
+			repo.Update(moved)
+			repo.Trash(2, 0, "", time.Now())
+
+			find := func(filter PostFilter, afterId uint64) string {
+				var ids []uint64
+				if err := repo.FindPosts(filter, afterId, func(post *m.Post) bool {
+					ids = append(ids, post.PostId)
+					return true
+				}); err != nil {
+					t.Fatalf("failed to find posts: %v", err)
+				}
+				return fmt.Sprint(ids)
+			}
+			day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+			for _, c := range []struct {
+				filter   PostFilter
+				afterId  uint64
+				expected string
+			}{
+				{PostFilter{}, 0, fmt.Sprint([]uint64{1, 3, 4, math.MaxUint64})},
+				{PostFilter{Author: "alice"}, 0, fmt.Sprint([]uint64{1, 4, math.MaxUint64})},
+				{PostFilter{Author: "alice"}, 1, fmt.Sprint([]uint64{4, math.MaxUint64})},
+				{PostFilter{Author: "alice", Tag: "grpc"}, 0, "[1]"},
+				{PostFilter{Tag: "rust"}, 0, "[]"},
+				{PostFilter{Author: "carol", Tag: "go"}, 0, "[3]"},
+				{PostFilter{PublishedFrom: day(2)}, 0, "[1 3]"},
+				{PostFilter{PublishedTo: day(2)}, 0, "[4]"},
+				{PostFilter{Tag: "go", PublishedFrom: day(1), PublishedTo: day(3)}, 0, "[1 4]"},
+			} {
+				if ids := find(c.filter, c.afterId); ids != c.expected {
+					t.Errorf("expected %+v after %d to find %s, got %s", c.filter, c.afterId, c.expected, ids)
+				}
+			}
+
+			descending := func(filter PostFilter, beforeId uint64) string {
+				var ids []uint64
+				if err := repo.FindPostsDescending(filter, beforeId, func(post *m.Post) bool {
+					ids = append(ids, post.PostId)
+					return true
+				}); err != nil {
+					t.Fatalf("failed to find posts: %v", err)
+				}
+				return fmt.Sprint(ids)
+			}
+			for _, c := range []struct {
+				filter   PostFilter
+				beforeId uint64
+				expected string
+			}{
+				{PostFilter{}, 0, fmt.Sprint([]uint64{math.MaxUint64, 4, 3, 1})},
+				{PostFilter{}, math.MaxUint64, "[4 3 1]"},
+				{PostFilter{Author: "alice"}, 4, "[1]"},
+				{PostFilter{Tag: "go", PublishedFrom: day(1)}, 0, "[4 3 1]"},
+			} {
+				if ids := descending(c.filter, c.beforeId); ids != c.expected {
+					t.Errorf("expected %+v before %d to find %s, got %s", c.filter, c.beforeId, c.expected, ids)
+				}
+			}
+
+			byDate := func(filter PostFilter, desc bool, after *PostCursor, limit int) string {
+				var ids []uint64
+				if err := repo.FindPostsByPublicationDate(filter, desc, after, func(post *m.Post) bool {
+					ids = append(ids, post.PostId)
+					return len(ids) < limit
+				}); err != nil {
+					t.Fatalf("failed to find posts by publication date: %v", err)
+				}
+				return fmt.Sprint(ids)
+			}
+			for _, c := range []struct {
+				filter   PostFilter
+				desc     bool
+				after    *PostCursor
+				expected string
+			}{
+				{PostFilter{}, false, nil, fmt.Sprint([]uint64{math.MaxUint64, 4, 1, 3})},
+				{PostFilter{}, true, nil, fmt.Sprint([]uint64{3, 1, 4, math.MaxUint64})},
+				{PostFilter{}, false, &PostCursor{PostId: math.MaxUint64}, "[4 1 3]"},
+				{PostFilter{}, true, &PostCursor{PublicationDate: day(3), PostId: 1}, fmt.Sprint([]uint64{4, math.MaxUint64})},
+				{PostFilter{Author: "alice"}, false, &PostCursor{PublicationDate: day(1), PostId: 4}, "[1]"},
+				{PostFilter{Tag: "go", PublishedFrom: day(2)}, true, nil, "[3 1]"},
+			} {
+				if ids := byDate(c.filter, c.desc, c.after, 10); ids != c.expected {
+					t.Errorf("expected %+v desc %t after %+v to find %s, got %s", c.filter, c.desc, c.after, c.expected, ids)
+				}
+			}
+			if ids := byDate(PostFilter{}, false, nil, 2); ids != fmt.Sprint([]uint64{math.MaxUint64, 4}) {
+				t.Errorf("expected to stop after 2 posts, got %s", ids)
+			}
+		})
+	}
+}
+
+func TestPostRepositoryCopies(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			post := newTestPost(1, "Title")
+			repo.Create(post)
+			post.Title = "Changed after create"
+			post.Tags[0] = "changed"
+
+			read, _ := repo.Read(1)
+			read.Title = "Changed after read"
+			read.Tags[0] = "changed"
+			repo.Scan(0, func(post *m.Post) bool {
+				post.Tags[0] = "changed"
+				return true
+			})
+			revisions, _ := repo.ListRevisions(1)
+			revisions[0].Post.Tags[0] = "changed"
+			revisions[0].ChangedFields[0] = "changed"
+
+			stored, _ := repo.Read(1)
+			if stored.Title != "Title" || fmt.Sprint(stored.Tags) != "[test]" {
+				t.Fatalf("expected stored post to be unchanged, got %+v", stored)
+			}
+			revision, _ := repo.ReadRevision(1, 1)
+			if fmt.Sprint(revision.Post.Tags) != "[test]" || revision.ChangedFields[0] != "title" {
+				t.Fatalf("expected stored revision to be unchanged, got %+v", revision)
+			}
+		})
+	}
+}
+
+// TestPostDAOConcurrentCopies changes posts read from the DAO while another
+// goroutine updates them; run with -race to check readers and writers never
+// share memory.
+func TestPostDAOConcurrentCopies(t *testing.T) {
+	dao := NewPostDAO()
+	dao.Create(newTestPost(1, "Title"))
+
+	var wg sync.WaitGroup
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				post, err := dao.Read(1)
+				if err != nil {
+					t.Errorf("failed to read post: %v", err)
+					return
+				}
+				post.Title = "Changed by reader"
+				post.Tags = append(post.Tags[:0], "changed")
+				dao.FindPosts(PostFilter{Tag: "test"}, 0, func(post *m.Post) bool {
+					post.Tags[0] = "changed"
+					return true
+				})
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			post, err := dao.Read(1)
+			if err != nil {
+				t.Errorf("failed to read post: %v", err)
+				return
+			}
+			post.Title = fmt.Sprintf("Title %d", i)
+			post.Tags = append(post.Tags[:0], "test")
+			if err := dao.Update(post); err != nil {
+				t.Errorf("failed to update post: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	post, _ := dao.Read(1)
+	if post.Title != "Title 199" || fmt.Sprint(post.Tags) != "[test]" {
+		t.Fatalf("expected only the writer's changes to be stored, got %+v", post)
+	}
+}
@@ -0,0 +1,58 @@
+package dao
+
+import m "cloudbees/models"
+
+// copyPost returns a copy of post that shares no memory with it.
+func copyPost(post *m.Post) *m.Post {
+	copied := *post
+	if post.Tags != nil {
+		copied.Tags = append([]string{}, post.Tags...)
+	}
+	return &copied
+}
+
+func copyRevision(revision *m.PostRevision) *m.PostRevision {
+	return &m.PostRevision{
+		Post:          *copyPost(&revision.Post),
+		ChangedFields: append([]string{}, revision.ChangedFields...),
+	}
+}
+
+// newRevision snapshots current, the post written over previous, which is nil
+// when current was just created.
+func newRevision(previous *m.Post, current *m.Post) *m.PostRevision {
+	return &m.PostRevision{
+		Post:          *copyPost(current),
+		ChangedFields: changedFields(previous, current),
+	}
+}
+
+// changedFields names the fields of current that differ from previous, or all
+// of them when there is no previous post.
+func changedFields(previous *m.Post, current *m.Post) []string {
+	if previous == nil {
+		return []string{"title", "content", "author", "publication_date", "tags"}
+	}
+	changed := []string{}
+	if previous.Title != current.Title {
+		changed = append(changed, "title")
+	}
+	if previous.Content != current.Content {
+		changed = append(changed, "content")
+	}
+	if previous.Author != current.Author {
+		changed = append(changed, "author")
+	}
+	if previous.PublicationDate != current.PublicationDate {
+		changed = append(changed, "publication_date")
+	}
+	if len(previous.Tags) != len(current.Tags) {
+		return append(changed, "tags")
+	}
+	for i := range previous.Tags {
+		if previous.Tags[i] != current.Tags[i] {
+			return append(changed, "tags")
+		}
+	}
+	return changed
+}
@@ -0,0 +1,453 @@
+package dao
+
+import (
+	m "cloudbees/models"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Snippets are cut from the post content, a window of snippetTokens tokens
+// starting snippetLead tokens before the first highlighted one.
+const (
+	snippetTokens = 30
+	snippetLead   = 10
+	ellipsis      = "…"
+)
+
+type searchField int
+
+const (
+	fieldTitle searchField = iota
+	fieldContent
+	fieldTags
+	fieldCount
+)
+
+// fieldWeights count a term in a title more than one in the tags, and one in
+// the tags more than one in the content.
+var fieldWeights = [fieldCount]float64{3, 1, 2}
+
+// SearchHit is a post matching a search query.
+type SearchHit struct {
+	Post  *m.Post
+	Score float64
+	// Snippet is an excerpt of the post content around the first match.
+	Snippet string
+	// Highlights are the [start, end) byte offsets of the matched words in
+	// Snippet.
+	Highlights [][2]int
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lowercase words of letters and digits, recording
+// where in text each word was found.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func terms(tokens []token) []string {
+	terms := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		terms = append(terms, t.term)
+	}
+	return terms
+}
+
+// clause is one part of a search query: a single term, a phrase of
+// consecutive terms, or a term prefix.
+type clause struct {
+	terms  []string
+	prefix bool
+}
+
+// parseQuery splits a query into clauses. Quoted text is a phrase and a word
+// ending in * is a prefix; every clause must match for a post to match.
+func parseQuery(query string) []clause {
+	var clauses []clause
+	addWords := func(text string, phrase bool) {
+		terms := terms(tokenize(text))
+		if len(terms) == 0 {
+			return
+		}
+		if phrase {
+			clauses = append(clauses, clause{terms: terms})
+			return
+		}
+		for _, term := range terms {
+			clauses = append(clauses, clause{terms: []string{term}})
+		}
+	}
+
+	parts := strings.Split(query, `"`)
+	for i, part := range parts {
+		// Odd parts were between quotes; an unterminated quote runs to the end.
+		if i%2 == 1 {
+			addWords(part, true)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if !strings.HasSuffix(word, "*") {
+				addWords(word, true)
+				continue
+			}
+			tokens := tokenize(word)
+			if len(tokens) == 0 {
+				continue
+			}
+			last := len(tokens) - 1
+			addWords(word[:tokens[last].start], false)
+			clauses = append(clauses, clause{terms: []string{tokens[last].term}, prefix: true})
+		}
+	}
+	return clauses
+}
+
+// termPostings records where a term occurs in one post.
+type termPostings struct {
+	// frequency is the field weighted number of occurrences.
+	frequency float64
+	positions [fieldCount][]int
+}
+
+// searchIndex is an inverted index over the title, content and tags of posts.
+// It is safe for concurrent use.
+type searchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[uint64]*termPostings
+	// terms holds the keys of postings in ascending order, for prefix queries.
+	terms       []string
+	docs        map[uint64]*indexedPost
+	totalLength float64
+}
+
+type indexedPost struct {
+	// length is the field weighted number of terms in the post.
+	length float64
+	terms  []string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[uint64]*termPostings),
+		docs:     make(map[uint64]*indexedPost),
+	}
+}
+
+// add indexes the post, replacing what was indexed for its id before.
+func (index *searchIndex) add(post *m.Post) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.removeLocked(post.PostId)
+
+	// Tags are indexed as one field with a gap between tags, so a phrase
+	// can't match across two of them.
+	var tags []string
+	for _, tag := range post.Tags {
+		for _, t := range tokenize(tag) {
+			tags = append(tags, t.term)
+		}
+		tags = append(tags, "")
+	}
+	fields := [fieldCount][]string{
+		fieldTitle:   terms(tokenize(post.Title)),
+		fieldContent: terms(tokenize(post.Content)),
+		fieldTags:    tags,
+	}
+
+	indexed := &indexedPost{}
+	for field, terms := range fields {
+		for position, term := range terms {
+			if term == "" {
+				continue
+			}
+			docs, exists := index.postings[term]
+			if !exists {
+				docs = make(map[uint64]*termPostings)
+				index.postings[term] = docs
+				index.insertTerm(term)
+			}
+			entry, exists := docs[post.PostId]
+			if !exists {
+				entry = &termPostings{}
+				docs[post.PostId] = entry
+				indexed.terms = append(indexed.terms, term)
+			}
+			entry.frequency += fieldWeights[field]
+			entry.positions[field] = append(entry.positions[field], position)
+			indexed.length += fieldWeights[field]
+		}
+	}
+	index.docs[post.PostId] = indexed
+	index.totalLength += indexed.length
+}
+
+// remove drops the post with the given id from the index.
+func (index *searchIndex) remove(id uint64) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.removeLocked(id)
+}
+
+func (index *searchIndex) removeLocked(id uint64) {
+	indexed, exists := index.docs[id]
+	if !exists {
+		return
+	}
+	delete(index.docs, id)
+	index.totalLength -= indexed.length
+	for _, term := range indexed.terms {
+		docs := index.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(index.postings, term)
+			index.removeTerm(term)
+		}
+	}
+}
+
+func (index *searchIndex) insertTerm(term string) {
+	i := sort.SearchStrings(index.terms, term)
+	index.terms = append(index.terms, "")
+	copy(index.terms[i+1:], index.terms[i:])
+	index.terms[i] = term
+}
+
+func (index *searchIndex) removeTerm(term string) {
+	i := sort.SearchStrings(index.terms, term)
+	if i < len(index.terms) && index.terms[i] == term {
+		index.terms = append(index.terms[:i], index.terms[i+1:]...)
+	}
+}
+
+// expand returns the indexed terms a clause term stands for.
+func (index *searchIndex) expand(c clause) []string {
+	if !c.prefix {
+		return c.terms
+	}
+	var expanded []string
+	for i := sort.SearchStrings(index.terms, c.terms[0]); i < len(index.terms); i++ {
+		if !strings.HasPrefix(index.terms[i], c.terms[0]) {
+			break
+		}
+		expanded = append(expanded, index.terms[i])
+	}
+	return expanded
+}
+
+// matchPhrase reports whether terms occur one after another in a field of
+// the post with the given id.
+func (index *searchIndex) matchPhrase(id uint64, terms []string) bool {
+	first := index.postings[terms[0]][id]
+	for field := searchField(0); field < fieldCount; field++ {
+		for _, start := range first.positions[field] {
+			matched := true
+			for offset, term := range terms[1:] {
+				if !containsInt(index.postings[term][id].positions[field], start+offset+1) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type scoredPost struct {
+	id    uint64
+	score float64
+	terms map[string]bool
+}
+
+// rank returns the posts matching every clause of the query, best first.
+func (index *searchIndex) rank(query string) []scoredPost {
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
+		return nil
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	matches := make(map[uint64]*scoredPost)
+	for i, c := range clauses {
+		// The posts containing every term of the clause, or any expansion
+		// of a prefix.
+		found := make(map[uint64]map[string]bool)
+		if c.prefix {
+			for _, term := range index.expand(c) {
+				for id := range index.postings[term] {
+					if found[id] == nil {
+						found[id] = make(map[string]bool)
+					}
+					found[id][term] = true
+				}
+			}
+		} else {
+			for id := range index.postings[c.terms[0]] {
+				all := true
+				for _, term := range c.terms[1:] {
+					if _, exists := index.postings[term][id]; !exists {
+						all = false
+						break
+					}
+				}
+				if all && (len(c.terms) == 1 || index.matchPhrase(id, c.terms)) {
+					found[id] = make(map[string]bool)
+					for _, term := range c.terms {
+						found[id][term] = true
+					}
+				}
+			}
+		}
+
+		if i == 0 {
+			for id, terms := range found {
+				matches[id] = &scoredPost{id: id, terms: terms}
+			}
+			continue
+		}
+		for id, match := range matches {
+			terms, exists := found[id]
+			if !exists {
+				delete(matches, id)
+				continue
+			}
+			for term := range terms {
+				match.terms[term] = true
+			}
+		}
+	}
+
+	count := float64(len(index.docs))
+	averageLength := index.totalLength / count
+	ranked := make([]scoredPost, 0, len(matches))
+	for id, match := range matches {
+		for term := range match.terms {
+			docs := index.postings[term]
+			frequency := docs[id].frequency
+			idf := math.Log(1 + (count-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			norm := 1 - bm25B + bm25B*index.docs[id].length/averageLength
+			match.score += idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*norm)
+		}
+		ranked = append(ranked, *match)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id < ranked[j].id
+	})
+	return ranked
+}
+
+// search ranks the posts matching query and returns the hits from offset up
+// to limit, looking posts up with lookup, along with the number of matches.
+// Posts lookup can't find any more are left out.
+func (index *searchIndex) search(query string, offset int, limit int, lookup func(id uint64) (*m.Post, error)) ([]SearchHit, int, error) {
+	ranked := index.rank(query)
+	total := len(ranked)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		ranked = ranked[:offset+limit]
+	}
+	hits := make([]SearchHit, 0, len(ranked)-offset)
+	for _, match := range ranked[offset:] {
+		post, err := lookup(match.id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if post == nil {
+			continue
+		}
+		snippet, highlights := buildSnippet(post.Content, match.terms)
+		hits = append(hits, SearchHit{
+			Post:       post,
+			Score:      match.score,
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+	return hits, total, nil
+}
+
+// buildSnippet cuts a window of content around the first of the terms and
+// returns it with the offsets of the terms in it.
+func buildSnippet(content string, terms map[string]bool) (string, [][2]int) {
+	tokens := tokenize(content)
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	first := 0
+	for i, t := range tokens {
+		if terms[t.term] {
+			first = i
+			break
+		}
+	}
+	start := first - snippetLead
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetTokens
+	if end > len(tokens) {
+		end = len(tokens)
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString(ellipsis)
+	}
+	base := tokens[start].start - snippet.Len()
+	snippet.WriteString(content[tokens[start].start:tokens[end-1].end])
+	if end < len(tokens) {
+		snippet.WriteString(ellipsis)
+	}
+
+	var highlights [][2]int
+	for _, t := range tokens[start:end] {
+		if terms[t.term] {
+			highlights = append(highlights, [2]int{t.start - base, t.end - base})
+		}
+	}
+	return snippet.String(), highlights
+}
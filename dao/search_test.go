@@ -0,0 +1,79 @@
+package dao
+
+import (
+	m "cloudbees/models"
+	"fmt"
+	"testing"
+)
+
+func searchIds(t *testing.T, index *searchIndex, query string) []uint64 {
+	t.Helper()
+	ids := []uint64{}
+	for _, match := range index.rank(query) {
+		ids = append(ids, match.id)
+	}
+	return ids
+}
+
+func TestSearchIndexQueries(t *testing.T) {
+	index := newSearchIndex()
+	index.add(&m.Post{PostId: 1, Title: "Concurrency in Go", Content: "Goroutines and channels make concurrent programs simple.", Tags: []string{"go"}})
+	index.add(&m.Post{PostId: 2, Title: "Rust ownership", Content: "Ownership rules keep concurrent Rust programs safe.", Tags: []string{"rust", "memory safety"}})
+	index.add(&m.Post{PostId: 3, Title: "Cooking pasta", Content: "Boil water, add salt, then the pasta.", Tags: []string{"food"}})
+
+	testCases := []struct {
+		query    string
+		expected []uint64
+	}{
+		{query: "GO", expected: []uint64{1}},
+		{query: "concurrent programs", expected: []uint64{1, 2}},
+		{query: `"programs simple"`, expected: []uint64{1}},
+		{query: `"simple programs"`, expected: []uint64{}},
+		{query: `"rust memory"`, expected: []uint64{}},
+		{query: `"memory safety"`, expected: []uint64{2}},
+		{query: "concurren*", expected: []uint64{1, 2}},
+		{query: "rust concurren*", expected: []uint64{2}},
+		{query: "pizza", expected: []uint64{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			if ids := searchIds(t, index, tc.query); fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+
+	index.add(&m.Post{PostId: 1, Title: "Generics in Go", Content: "Type parameters.", Tags: []string{"go"}})
+	if ids := searchIds(t, index, "goroutines"); len(ids) != 0 {
+		t.Fatalf("expected replaced content to be unindexed, got %v", ids)
+	}
+	index.remove(2)
+	if ids := searchIds(t, index, "rust"); len(ids) != 0 {
+		t.Fatalf("expected removed post to be unindexed, got %v", ids)
+	}
+	if len(index.terms) != len(index.postings) {
+		t.Fatalf("term list out of sync with postings: %d terms, %d postings", len(index.terms), len(index.postings))
+	}
+}
+
+func TestSearchIndexRanking(t *testing.T) {
+	index := newSearchIndex()
+	index.add(&m.Post{PostId: 1, Title: "Notes", Content: "A short mention of kubernetes among many other words here."})
+	index.add(&m.Post{PostId: 2, Title: "Kubernetes", Content: "Kubernetes schedules containers."})
+	index.add(&m.Post{PostId: 3, Title: "Unrelated", Content: "Nothing to see."})
+
+	if ids := searchIds(t, index, "kubernetes"); fmt.Sprint(ids) != "[2 1]" {
+		t.Fatalf("expected the post about kubernetes to rank first, got %v", ids)
+	}
+}
+
+func TestBuildSnippet(t *testing.T) {
+	content := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen target sixteen"
+	snippet, highlights := buildSnippet(content, map[string]bool{"target": true})
+	if snippet[:len(ellipsis)] != ellipsis {
+		t.Fatalf("expected snippet to start with an ellipsis, got %q", snippet)
+	}
+	if len(highlights) != 1 || snippet[highlights[0][0]:highlights[0][1]] != "target" {
+		t.Fatalf("expected target to be highlighted in %q, got %v", snippet, highlights)
+	}
+}
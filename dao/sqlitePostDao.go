@@ -0,0 +1,792 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order on startup; a migration's version is its
+// index plus one. Never edit a released migration, append a new one instead.
+var migrations = []string{
+	`CREATE TABLE posts (
+		post_id          INTEGER PRIMARY KEY,
+		title            TEXT NOT NULL,
+		content          TEXT NOT NULL,
+		author           TEXT NOT NULL,
+		publication_date TEXT NOT NULL
+	);
+	CREATE TABLE post_tags (
+		post_id  INTEGER NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		tag      TEXT NOT NULL,
+		PRIMARY KEY (post_id, position)
+	);
+	CREATE INDEX post_tags_tag ON post_tags (tag);`,
+	`ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	`CREATE TABLE post_id_sequence (last_id INTEGER NOT NULL);
+	INSERT INTO post_id_sequence (last_id) SELECT COALESCE(MAX(post_id), 0) FROM posts;`,
+	`ALTER TABLE posts ADD COLUMN updated_by TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+	CREATE TABLE post_revisions (
+		post_id          INTEGER NOT NULL,
+		version          INTEGER NOT NULL,
+		title            TEXT NOT NULL,
+		content          TEXT NOT NULL,
+		author           TEXT NOT NULL,
+		publication_date TEXT NOT NULL,
+		tags             TEXT NOT NULL,
+		updated_by       TEXT NOT NULL,
+		updated_at       TEXT NOT NULL,
+		changed_fields   TEXT NOT NULL,
+		PRIMARY KEY (post_id, version)
+	);
+	INSERT INTO post_revisions
+		SELECT post_id, version, title, content, author, publication_date,
+			(SELECT json_group_array(tag) FROM (SELECT tag FROM post_tags WHERE post_tags.post_id = posts.post_id ORDER BY position)),
+			'', '', '[]'
+		FROM posts;`,
+	`ALTER TABLE posts ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN deleted_at TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE posts ADD COLUMN published_on TEXT NOT NULL DEFAULT '';
+	UPDATE posts SET published_on = substr(publication_date, 7, 4) || '-' || substr(publication_date, 4, 2) || '-' || substr(publication_date, 1, 2)
+		WHERE publication_date GLOB '[0-9][0-9]-[0-9][0-9]-[0-9][0-9][0-9][0-9]';
+	CREATE INDEX posts_author ON posts (author, post_id);
+	CREATE INDEX posts_published_on ON posts (published_on, post_id);`,
+	`UPDATE post_id_sequence SET last_id = (SELECT COALESCE(MAX(post_id), 0) FROM posts WHERE post_id > 0)
+		WHERE last_id < 0;`,
+}
+
+// scanBatchSize is how many posts Scan loads per query.
+const scanBatchSize = 100
+
+// postColumns are the posts columns scanPost reads, in order.
+const postColumns = `post_id, title, content, author, publication_date, version, updated_by, updated_at, deleted_by, deleted_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLitePostDAO is a PostRepository stored in a single SQLite database file.
+//
+// SQLite integers are signed, so post ids are stored bit for bit as int64 and
+// ids above math.MaxInt64 come back negative; queries that order by id sort
+// negative ids last to keep unsigned id order.
+//
+// Posts keep their publication date as yyyy-mm-dd in published_on, which is
+// indexed along with author and tags for FindPosts.
+//
+// The search index lives in memory, rebuilt from the database on startup.
+type SQLitePostDAO struct {
+	db *sql.DB
+	// mu serializes writes so the search index is updated in commit order.
+	mu    sync.Mutex
+	index *searchIndex
+}
+
+var _ PostRepository = (*SQLitePostDAO)(nil)
+
+// NewSQLitePostDAO opens or creates the SQLite database at path and brings
+// its schema up to date.
+func NewSQLitePostDAO(path string) (*SQLitePostDAO, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids "database is
+	// locked" errors between our own transactions.
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	dao := &SQLitePostDAO{db: db, index: newSearchIndex()}
+	err = dao.Scan(0, func(post *m.Post) bool {
+		dao.index.add(post)
+		return true
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return dao, nil
+}
+
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock acquires mu, recording how long it waited.
+func (dao *SQLitePostDAO) lock() {
+	start := time.Now()
+	dao.mu.Lock()
+	writeLockWait.Observe(time.Since(start).Seconds())
+}
+
+// Close closes the underlying database.
+func (dao *SQLitePostDAO) Close() error {
+	return dao.db.Close()
+}
+
+func (dao *SQLitePostDAO) Create(post *m.Post) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	id := post.PostId
+	err := dao.inTx(func(tx *sql.Tx) error {
+		if post.PostId == 0 {
+			last, err := lastId(tx)
+			if err != nil {
+				return err
+			}
+			if last >= maxGeneratedId {
+				return e.IdSpaceExhaustedError
+			}
+			post.PostId = last + 1
+		}
+
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = ?)`, int64(post.PostId)).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return e.EntityAlreadyExistsError
+		}
+		return insertPost(tx, post, 1)
+	})
+	if err != nil {
+		post.PostId = id
+		return err
+	}
+	post.Version = 1
+	dao.index.add(post)
+	return nil
+}
+
+func (dao *SQLitePostDAO) Upsert(post *m.Post) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	var version uint64 = 1
+	err := dao.inTx(func(tx *sql.Tx) error {
+		stored, deleted, err := storedVersion(tx, post.PostId)
+		if errors.Is(err, e.EnitityNotFoundError) {
+			if post.Version != 0 {
+				return e.VersionConflictError
+			}
+			return insertPost(tx, post, version)
+		}
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return e.EntityAlreadyExistsError
+		}
+		if post.Version != 0 && post.Version != stored {
+			return e.VersionConflictError
+		}
+		version = stored + 1
+		return updatePost(tx, post, version)
+	})
+	if err == nil {
+		post.Version = version
+		dao.index.add(post)
+	}
+	return err
+}
+
+func (dao *SQLitePostDAO) Read(id uint64) (*m.Post, error) {
+	return readPost(dao.db, id)
+}
+
+func readPost(q querier, id uint64) (*m.Post, error) {
+	post, err := scanPost(q.QueryRow(`SELECT `+postColumns+` FROM posts WHERE post_id = ? AND deleted_at = ''`, int64(id)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, e.EnitityNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadTags(q, []*m.Post{post}); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// scanPost reads the postColumns of a row into a post without tags.
+func scanPost(row rowScanner) (*m.Post, error) {
+	post := &m.Post{}
+	var id int64
+	var updatedAt, deletedAt string
+	err := row.Scan(&id, &post.Title, &post.Content, &post.Author, &post.PublicationDate, &post.Version, &post.UpdatedBy, &updatedAt, &post.DeletedBy, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	post.PostId = uint64(id)
+	if post.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	post.DeletedAt, err = parseTime(deletedAt)
+	return post, err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (dao *SQLitePostDAO) Update(post *m.Post) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	var version uint64
+	err := dao.inTx(func(tx *sql.Tx) error {
+		stored, err := checkVersion(tx, post.PostId, post.Version)
+		if err != nil {
+			return err
+		}
+		version = stored + 1
+		return updatePost(tx, post, version)
+	})
+	if err == nil {
+		post.Version = version
+		dao.index.add(post)
+	}
+	return err
+}
+
+func (dao *SQLitePostDAO) Delete(id uint64) error {
+	return dao.DeleteVersion(id, 0)
+}
+
+func (dao *SQLitePostDAO) DeleteVersion(id uint64, version uint64) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	err := dao.inTx(func(tx *sql.Tx) error {
+		stored, _, err := storedVersion(tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != stored {
+			return e.VersionConflictError
+		}
+		return deletePost(tx, id)
+	})
+	if err == nil {
+		dao.index.remove(id)
+	}
+	return err
+}
+
+func deletePost(tx *sql.Tx, id uint64) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = ?`, int64(id)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM post_revisions WHERE post_id = ?`, int64(id)); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM posts WHERE post_id = ?`, int64(id))
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+func (dao *SQLitePostDAO) Trash(id uint64, version uint64, deletedBy string, deletedAt time.Time) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	err := dao.inTx(func(tx *sql.Tx) error {
+		if _, err := checkVersion(tx, id, version); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE posts SET deleted_by = ?, deleted_at = ? WHERE post_id = ?`, deletedBy, formatTime(deletedAt), int64(id))
+		return err
+	})
+	if err == nil {
+		dao.index.remove(id)
+	}
+	return err
+}
+
+func (dao *SQLitePostDAO) Restore(id uint64) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	var restored *m.Post
+	err := dao.inTx(func(tx *sql.Tx) error {
+		_, deleted, err := storedVersion(tx, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return e.EnitityNotFoundError
+		}
+		if _, err := tx.Exec(`UPDATE posts SET deleted_by = '', deleted_at = '' WHERE post_id = ?`, int64(id)); err != nil {
+			return err
+		}
+		restored, err = readPost(tx, id)
+		return err
+	})
+	if err == nil {
+		dao.index.add(restored)
+	}
+	return err
+}
+
+func (dao *SQLitePostDAO) Purge(id uint64) error {
+	dao.lock()
+	defer dao.mu.Unlock()
+	return dao.inTx(func(tx *sql.Tx) error {
+		_, deleted, err := storedVersion(tx, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return e.EnitityNotFoundError
+		}
+		return deletePost(tx, id)
+	})
+}
+
+// Scan calls fn for every post with an id greater than afterId in ascending
+// id order, stopping as soon as fn returns false. Posts are loaded in batches
+// and fn runs without holding a database connection.
+func (dao *SQLitePostDAO) Scan(afterId uint64, fn func(post *m.Post) bool) error {
+	return dao.scan(afterId, false, `deleted_at = ''`, nil, fn)
+}
+
+func (dao *SQLitePostDAO) ScanDeleted(afterId uint64, fn func(post *m.Post) bool) error {
+	return dao.scan(afterId, false, `deleted_at != ''`, nil, fn)
+}
+
+func (dao *SQLitePostDAO) Count() (int, error) {
+	var count int
+	err := dao.db.QueryRow(`SELECT COUNT(*) FROM posts WHERE deleted_at = ''`).Scan(&count)
+	return count, err
+}
+
+func (dao *SQLitePostDAO) FindPosts(filter PostFilter, afterId uint64, fn func(post *m.Post) bool) error {
+	conditions, args := filterConditions(filter)
+	return dao.scan(afterId, false, conditions, args, fn)
+}
+
+func (dao *SQLitePostDAO) FindPostsDescending(filter PostFilter, beforeId uint64, fn func(post *m.Post) bool) error {
+	conditions, args := filterConditions(filter)
+	return dao.scan(beforeId, true, conditions, args, fn)
+}
+
+// filterConditions returns the SQL conditions selecting the posts that are
+// not in the trash and match the filter, with their arguments.
+func filterConditions(filter PostFilter) (string, []interface{}) {
+	conditions := []string{`deleted_at = ''`}
+	var args []interface{}
+	if filter.Author != "" {
+		conditions = append(conditions, `author = ?`)
+		args = append(args, filter.Author)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, `post_id IN (SELECT post_id FROM post_tags WHERE tag = ?)`)
+		args = append(args, filter.Tag)
+	}
+	if filter.dateBounded() {
+		conditions = append(conditions, `published_on != ''`)
+	}
+	if !filter.PublishedFrom.IsZero() {
+		conditions = append(conditions, `published_on >= ?`)
+		args = append(args, dateKey(filter.PublishedFrom))
+	}
+	if !filter.PublishedTo.IsZero() {
+		conditions = append(conditions, `published_on <= ?`)
+		args = append(args, dateKey(filter.PublishedTo))
+	}
+	return strings.Join(conditions, ` AND `), args
+}
+
+func (dao *SQLitePostDAO) FindPostsByPublicationDate(filter PostFilter, desc bool, after *PostCursor, fn func(post *m.Post) bool) error {
+	conditions, args := filterConditions(filter)
+	order, past := `published_on, post_id < 0, post_id`, `>`
+	if desc {
+		order, past = `published_on DESC, post_id < 0 DESC, post_id DESC`, `<`
+	}
+	var cursor *datedId
+	if after != nil {
+		cursor = &datedId{date: dateKey(after.PublicationDate), id: after.PostId}
+	}
+	for {
+		query, queryArgs := conditions, args
+		if cursor != nil {
+			query += ` AND (published_on ` + past + ` ? OR (published_on = ? AND ` + idCondition(cursor.id, desc) + `))`
+			queryArgs = append(append([]interface{}{}, args...), cursor.date, cursor.date, int64(cursor.id))
+		}
+		rows, err := dao.db.Query(`SELECT `+postColumns+` FROM posts WHERE `+query+` ORDER BY `+order+` LIMIT ?`,
+			append(queryArgs, scanBatchSize)...)
+		if err != nil {
+			return err
+		}
+		batch, err := scanPosts(rows)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		if err := loadTags(dao.db, batch); err != nil {
+			return err
+		}
+		for _, post := range batch {
+			if !fn(post) {
+				return nil
+			}
+		}
+		last := batch[len(batch)-1]
+		cursor = &datedId{date: publishedOn(last), id: last.PostId}
+	}
+}
+
+// idCondition selects the ids after id in unsigned order, or before it if
+// before is set.
+func idCondition(id uint64, before bool) string {
+	switch {
+	case !before && id > math.MaxInt64:
+		return `(post_id < 0 AND post_id > ?)`
+	case !before:
+		return `(post_id > ? OR post_id < 0)`
+	case id > math.MaxInt64:
+		return `(post_id >= 0 OR post_id < ?)`
+	default:
+		return `(post_id >= 0 AND post_id < ?)`
+	}
+}
+
+// scan calls fn for the posts that meet the SQL conditions after the cursor
+// id in ascending id order, or before it in descending order if desc is set,
+// where a cursor of 0 starts from the highest id.
+func (dao *SQLitePostDAO) scan(cursor uint64, desc bool, conditions string, args []interface{}, fn func(post *m.Post) bool) error {
+	for {
+		batch, err := dao.scanBatch(cursor, desc, conditions, args)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		for _, post := range batch {
+			if !fn(post) {
+				return nil
+			}
+		}
+		cursor = batch[len(batch)-1].PostId
+		if (!desc && cursor == math.MaxUint64) || (desc && cursor == 0) {
+			return nil
+		}
+	}
+}
+
+func (dao *SQLitePostDAO) scanBatch(cursor uint64, desc bool, conditions string, args []interface{}) ([]*m.Post, error) {
+	order := `post_id < 0, post_id`
+	if desc {
+		order = `post_id < 0 DESC, post_id DESC`
+	}
+	query, queryArgs := conditions, append([]interface{}{}, args...)
+	if !desc || cursor != 0 {
+		query = idCondition(cursor, desc) + ` AND ` + conditions
+		queryArgs = append([]interface{}{int64(cursor)}, args...)
+	}
+	rows, err := dao.db.Query(`SELECT `+postColumns+` FROM posts
+		WHERE `+query+` ORDER BY `+order+` LIMIT ?`,
+		append(queryArgs, scanBatchSize)...)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return batch, loadTags(dao.db, batch)
+}
+
+// scanPosts reads and closes rows of postColumns. Rows are closed before
+// returning so the connection is free for loadTags.
+func scanPosts(rows *sql.Rows) ([]*m.Post, error) {
+	defer rows.Close()
+	posts := make([]*m.Post, 0, scanBatchSize)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+func (dao *SQLitePostDAO) Search(query string, offset int, limit int) ([]SearchHit, int, error) {
+	return dao.index.search(query, offset, limit, func(id uint64) (*m.Post, error) {
+		post, err := dao.Read(id)
+		if errors.Is(err, e.EnitityNotFoundError) {
+			return nil, nil
+		}
+		return post, err
+	})
+}
+
+func (dao *SQLitePostDAO) ListRevisions(id uint64) ([]*m.PostRevision, error) {
+	var exists bool
+	if err := dao.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = ? AND deleted_at = '')`, int64(id)).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, e.EnitityNotFoundError
+	}
+	rows, err := dao.db.Query(`SELECT `+revisionColumns+` FROM post_revisions WHERE post_id = ? ORDER BY version`, int64(id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []*m.PostRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (dao *SQLitePostDAO) ReadRevision(id uint64, version uint64) (*m.PostRevision, error) {
+	revision, err := scanRevision(dao.db.QueryRow(`SELECT `+revisionColumns+` FROM post_revisions WHERE post_id = ? AND version = ?
+		AND NOT EXISTS (SELECT 1 FROM posts WHERE post_id = ? AND deleted_at != '')`, int64(id), version, int64(id)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, e.EnitityNotFoundError
+	}
+	return revision, err
+}
+
+// revisionColumns are the post_revisions columns scanRevision reads, in order.
+const revisionColumns = `post_id, version, title, content, author, publication_date, tags, updated_by, updated_at, changed_fields`
+
+func scanRevision(row rowScanner) (*m.PostRevision, error) {
+	revision := &m.PostRevision{}
+	post := &revision.Post
+	var id int64
+	var tags, updatedAt, changedFields string
+	err := row.Scan(&id, &post.Version, &post.Title, &post.Content, &post.Author, &post.PublicationDate, &tags, &post.UpdatedBy, &updatedAt, &changedFields)
+	if err != nil {
+		return nil, err
+	}
+	post.PostId = uint64(id)
+	if post.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &post.Tags); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(changedFields), &revision.ChangedFields); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+func insertRevision(tx *sql.Tx, revision *m.PostRevision) error {
+	post := revision.Post
+	if post.Tags == nil {
+		post.Tags = []string{}
+	}
+	tags, err := json.Marshal(post.Tags)
+	if err != nil {
+		return err
+	}
+	changedFields, err := json.Marshal(revision.ChangedFields)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO post_revisions (`+revisionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		int64(post.PostId), post.Version, post.Title, post.Content, post.Author, post.PublicationDate,
+		string(tags), post.UpdatedBy, formatTime(post.UpdatedAt), string(changedFields))
+	return err
+}
+
+// loadTags fills in the tags of posts, in their original order.
+func loadTags(q querier, posts []*m.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byId := make(map[int64]*m.Post, len(posts))
+	args := make([]interface{}, 0, len(posts))
+	for _, post := range posts {
+		post.Tags = []string{}
+		byId[int64(post.PostId)] = post
+		args = append(args, int64(post.PostId))
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(posts)), ", ")
+	rows, err := q.Query(`SELECT post_id, tag FROM post_tags WHERE post_id IN (`+placeholders+`) ORDER BY post_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		byId[id].Tags = append(byId[id].Tags, tag)
+	}
+	return rows.Err()
+}
+
+// lastId returns the highest id up to maxGeneratedId ever created, the base of
+// the id sequence.
+func lastId(tx *sql.Tx) (uint64, error) {
+	var last int64
+	err := tx.QueryRow(`SELECT last_id FROM post_id_sequence`).Scan(&last)
+	return uint64(last), err
+}
+
+func insertPost(tx *sql.Tx, post *m.Post, version uint64) error {
+	last, err := lastId(tx)
+	if err != nil {
+		return err
+	}
+	if post.PostId > last && post.PostId <= maxGeneratedId {
+		if _, err := tx.Exec(`UPDATE post_id_sequence SET last_id = ?`, int64(post.PostId)); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO posts (`+postColumns+`, published_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		int64(post.PostId), post.Title, post.Content, post.Author, post.PublicationDate, version, post.UpdatedBy, formatTime(post.UpdatedAt),
+		post.DeletedBy, formatTime(post.DeletedAt), publishedOn(post))
+	if err != nil {
+		return err
+	}
+	if err := replaceTags(tx, post); err != nil {
+		return err
+	}
+	return insertRevision(tx, newRevision(nil, withVersion(post, version)))
+}
+
+func updatePost(tx *sql.Tx, post *m.Post, version uint64) error {
+	previous, err := readPost(tx, post.PostId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE posts SET title = ?, content = ?, author = ?, publication_date = ?, published_on = ?, version = ?, updated_by = ?, updated_at = ? WHERE post_id = ?`,
+		post.Title, post.Content, post.Author, post.PublicationDate, publishedOn(post), version, post.UpdatedBy, formatTime(post.UpdatedAt), int64(post.PostId))
+	if err != nil {
+		return err
+	}
+	if err := replaceTags(tx, post); err != nil {
+		return err
+	}
+	return insertRevision(tx, newRevision(previous, withVersion(post, version)))
+}
+
+// withVersion returns a copy of post at the given version.
+func withVersion(post *m.Post, version uint64) *m.Post {
+	copied := copyPost(post)
+	copied.Version = version
+	return copied
+}
+
+func replaceTags(tx *sql.Tx, post *m.Post) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = ?`, int64(post.PostId)); err != nil {
+		return err
+	}
+	for position, tag := range post.Tags {
+		if _, err := tx.Exec(`INSERT INTO post_tags (post_id, position, tag) VALUES (?, ?, ?)`, int64(post.PostId), position, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedVersion returns the stored version of the post and whether it is in
+// the trash.
+func storedVersion(tx *sql.Tx, id uint64) (uint64, bool, error) {
+	var stored uint64
+	var deleted bool
+	err := tx.QueryRow(`SELECT version, deleted_at != '' FROM posts WHERE post_id = ?`, int64(id)).Scan(&stored, &deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, e.EnitityNotFoundError
+	}
+	return stored, deleted, err
+}
+
+// checkVersion returns the stored version of the post, failing if the post
+// doesn't exist or is in the trash, or if version is non-zero and doesn't
+// match it.
+func checkVersion(tx *sql.Tx, id uint64, version uint64) (uint64, error) {
+	stored, deleted, err := storedVersion(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	if deleted {
+		return 0, e.EnitityNotFoundError
+	}
+	if version != 0 && version != stored {
+		return 0, e.VersionConflictError
+	}
+	return stored, nil
+}
+
+func expectRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return e.EnitityNotFoundError
+	}
+	return nil
+}
+
+func (dao *SQLitePostDAO) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"bufio"
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// walOp identifies the mutation a write-ahead log record describes.
+type walOp uint8
+
+const (
+	walCreate walOp = iota + 1
+	walUpdate
+	walDelete
+	walTrash
+	walRestore
+)
+
+// walHeaderSize is the size of the payload length and CRC32 prefix of a record.
+const walHeaderSize = 8
+
+type walRecord struct {
+	Op     walOp   `json:"op"`
+	PostId uint64  `json:"post_id"`
+	Post   *m.Post `json:"post,omitempty"`
+}
+
+// walFile is the part of *os.File the write-ahead log uses.
+type walFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// writeAheadLog is an append-only file of length-prefixed, checksummed JSON
+// records. Every append is fsynced before it returns.
+type writeAheadLog struct {
+	file walFile
+	size int64
+	// failed is set when a failed append couldn't be cut off the log, which
+	// then rejects every later append: replaying it would apply the write.
+	failed bool
+}
+
+// openWriteAheadLog opens or creates the log at path and calls apply for every
+// record in it, in order. A torn last record, left behind by a crash in the
+// middle of an append, is truncated away; corruption anywhere else is an error.
+func openWriteAheadLog(path string, apply func(record walRecord)) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	end, err := replay(file, apply)
+	if err == nil {
+		err = truncateTo(file, end)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &writeAheadLog{file: file, size: end}, nil
+}
+
+// replay applies every intact record and returns the offset just past the last one.
+func replay(file *os.File, apply func(record walRecord)) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	reader := bufio.NewReader(file)
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return offset, nil
+		} else if err != nil {
+			return 0, err
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		end := offset + walHeaderSize + int64(length)
+		if end > size {
+			// Only the last record can be torn: a length running past the
+			// end of the file with intact records behind it is corrupt.
+			rest, err := io.ReadAll(reader)
+			if err != nil {
+				return 0, err
+			}
+			if holdsRecord(rest) {
+				return 0, e.CorruptLogError
+			}
+			return offset, nil
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return 0, err
+		}
+		var record walRecord
+		if crc32.ChecksumIEEE(payload) != checksum || json.Unmarshal(payload, &record) != nil {
+			if end == size {
+				return offset, nil
+			}
+			return 0, e.CorruptLogError
+		}
+		apply(record)
+		offset = end
+	}
+}
+
+// holdsRecord reports whether an intact record starts anywhere in data.
+func holdsRecord(data []byte) bool {
+	for start := 0; start+walHeaderSize <= len(data); start++ {
+		length := binary.LittleEndian.Uint32(data[start : start+4])
+		checksum := binary.LittleEndian.Uint32(data[start+4 : start+8])
+		end := int64(start) + walHeaderSize + int64(length)
+		if end > int64(len(data)) {
+			continue
+		}
+		payload := data[start+walHeaderSize : end]
+		if crc32.ChecksumIEEE(payload) == checksum && json.Valid(payload) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateTo(file walFile, offset int64) error {
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (wal *writeAheadLog) append(record walRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+	if wal.failed {
+		return e.WriteAheadLogFailedError
+	}
+	_, err = wal.file.Write(buf)
+	if err == nil {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		// Drop whatever part of the record made it to disk, so that neither
+		// replay nor the next append finds it.
+		if truncateTo(wal.file, wal.size) != nil {
+			wal.failed = true
+		}
+		return err
+	}
+	wal.size += int64(len(buf))
+	return nil
+}
+
+func (wal *writeAheadLog) close() error {
+	return wal.file.Close()
+}
@@ -0,0 +1,200 @@
+package dao
+
+import (
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestPost(id uint64, title string) *m.Post {
+	return &m.Post{
+		PostId:          id,
+		Title:           title,
+		Content:         "Content",
+		Author:          "Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	}
+}
+
+func TestFilePostDAORecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	for id := uint64(1); id <= 3; id++ {
+		if err := dao.Create(newTestPost(id, "Title")); err != nil {
+			t.Fatalf("failed to create post: %v", err)
+		}
+	}
+	if err := dao.Update(newTestPost(2, "Updated Title")); err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+	if err := dao.Delete(3); err != nil {
+		t.Fatalf("failed to delete post: %v", err)
+	}
+	dao.Close()
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+
+	post, err := dao.Read(2)
+	if err != nil || post.Title != "Updated Title" {
+		t.Fatalf("expected updated post 2, got %v, %v", post, err)
+	}
+	if _, err := dao.Read(1); err != nil {
+		t.Fatalf("expected post 1 to survive restart: %v", err)
+	}
+	if _, err := dao.Read(3); err != e.EnitityNotFoundError {
+		t.Fatalf("expected post 3 to stay deleted, got %v", err)
+	}
+}
+
+func TestFilePostDAOTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(1, "Title"))
+	dao.Create(newTestPost(2, "Title"))
+	dao.Close()
+
+	// Simulate a crash halfway through appending the second record.
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to recover from torn record: %v", err)
+	}
+	if _, err := dao.Read(1); err != nil {
+		t.Fatalf("expected post 1 to be recovered: %v", err)
+	}
+	if _, err := dao.Read(2); err != e.EnitityNotFoundError {
+		t.Fatalf("expected torn post 2 to be dropped, got %v", err)
+	}
+
+	// The log must accept new records after the torn tail was dropped.
+	dao.Create(newTestPost(3, "Title"))
+	dao.Close()
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	if _, err := dao.Read(3); err != nil {
+		t.Fatalf("expected post 3 to be recovered: %v", err)
+	}
+}
+
+func TestFilePostDAOCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	dao.Create(newTestPost(1, "Title"))
+	dao.Create(newTestPost(2, "Title"))
+	dao.Close()
+
+	// Flip a payload byte of the first record, which is followed by another.
+	data, _ := os.ReadFile(path)
+	data[walHeaderSize] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	if _, err := NewFilePostDAO(path); err != e.CorruptLogError {
+		t.Fatalf("expected %v, got %v", e.CorruptLogError, err)
+	}
+}
+
+func TestFilePostDAOCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	for id := uint64(1); id <= 5; id++ {
+		dao.Create(newTestPost(id, "Title"))
+	}
+	dao.Close()
+
+	// Make the length of the second record run past the end of the log.
+	data, _ := os.ReadFile(path)
+	second := walHeaderSize + binary.LittleEndian.Uint32(data[0:4])
+	binary.LittleEndian.PutUint32(data[second:second+4], uint32(len(data)))
+	os.WriteFile(path, data, 0o644)
+
+	if _, err := NewFilePostDAO(path); err != e.CorruptLogError {
+		t.Fatalf("expected %v, got %v", e.CorruptLogError, err)
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(len(data)) {
+		t.Fatalf("expected the log to be left whole at %d bytes, got %d", len(data), info.Size())
+	}
+}
+
+// failingSyncFile fails the next failures syncs of the file it wraps.
+type failingSyncFile struct {
+	*os.File
+	failures int
+}
+
+func (f *failingSyncFile) Sync() error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("sync failed")
+	}
+	return f.File.Sync()
+}
+
+func TestFilePostDAOFailedSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.wal")
+
+	dao, err := NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	file := &failingSyncFile{File: dao.wal.file.(*os.File), failures: 1}
+	dao.wal.file = file
+	if err := dao.Create(newTestPost(1, "Title")); err == nil {
+		t.Fatalf("expected the create to fail with the sync")
+	}
+	if err := dao.Create(newTestPost(2, "Title")); err != nil {
+		t.Fatalf("expected the log to recover from a failed sync: %v", err)
+	}
+
+	// Failing to cut off the failed record too leaves the log failed.
+	file.failures = 2
+	if err := dao.Create(newTestPost(3, "Title")); err == nil {
+		t.Fatalf("expected the create to fail with the sync")
+	}
+	if err := dao.Create(newTestPost(4, "Title")); err != e.WriteAheadLogFailedError {
+		t.Fatalf("expected %v, got %v", e.WriteAheadLogFailedError, err)
+	}
+	dao.Close()
+
+	dao, err = NewFilePostDAO(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dao: %v", err)
+	}
+	defer dao.Close()
+	for id, found := range map[uint64]bool{1: false, 2: true, 4: false} {
+		if _, err := dao.Read(id); (err == nil) != found {
+			t.Fatalf("expected post %d found %v after replay, got %v", id, found, err)
+		}
+	}
+}
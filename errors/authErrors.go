@@ -0,0 +1,13 @@
+package errors
+
+import "errors"
+
+var AuthenticationMissingError = errors.New("Authentication is missing, send a bearer token in the authorization metadata")
+var InvalidTokenError = errors.New("Bearer token is invalid or expired")
+var PermissionDeniedError = errors.New("Permission denied, the caller's roles don't allow this method")
+var NotPostAuthorError = errors.New("Only editors can write posts of other authors")
+var InvalidApiKeyError = errors.New("API key is invalid, expired or revoked")
+var ApiKeyNameMissingError = errors.New("API key Name is missing")
+var InvalidApiKeyScopeError = errors.New("API key Scope is invalid, should be SCOPE_READ or SCOPE_WRITE")
+var InvalidApiKeyExpiryError = errors.New("API key Expire Time is invalid, should be in the future")
+var ApiKeyManagementError = errors.New("API keys can't manage API keys, authenticate with a bearer token")
@@ -3,3 +3,12 @@ package errors
 import "errors"
 
 var EnitityNotFoundError = errors.New("Entity Not Found")
+var CorruptLogError = errors.New("Write-ahead log is corrupt")
+var WriteAheadLogFailedError = errors.New("Write-ahead log failed, restart to recover")
+var VersionConflictError = errors.New("Entity version does not match the expected version")
+var EntityAlreadyExistsError = errors.New("Entity Already Exists")
+var IdSpaceExhaustedError = errors.New("No Entity Ids left to allocate")
+var BlogNotFoundError = errors.New("Blog Not Found")
+var BlogAlreadyExistsError = errors.New("Blog Already Exists")
+var InvalidBlogIdError = errors.New("Blog Id is invalid, use 1 to 63 lowercase letters, digits and dashes, starting with a letter or digit")
+var DefaultBlogDeletionError = errors.New("The default Blog can't be deleted")
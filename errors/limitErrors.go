@@ -0,0 +1,6 @@
+package errors
+
+import "errors"
+
+var RateLimitedError = errors.New("Rate limit exceeded, retry after the time in the retry-after metadata")
+var ServerOverloadedError = errors.New("Server is overloaded, retry after the time in the retry-after metadata")
@@ -9,3 +9,10 @@ var AuthorMissingError = errors.New("Author is missing")
 var PublicationDateMissingError = errors.New("Publication Date is missing")
 var InvalidPublicationDateError = errors.New("Publication Date is invalid, should be in the format dd-mm-yyyy")
 var TagsMissingError = errors.New("Tags are missing")
+var InvalidPageSizeError = errors.New("Page Size is invalid, should not be negative")
+var InvalidPageTokenError = errors.New("Page Token is invalid or does not match the request")
+var InvalidOrderByError = errors.New("Order By is invalid, should be one of post_id, title, author, publication_date optionally followed by asc or desc")
+var InvalidDateRangeError = errors.New("Publication Date range is invalid, from should not be after to")
+var InvalidUpdateMaskError = errors.New("Update Mask is invalid, should only contain title, content, author, publication_date, tags or *")
+var SearchQueryMissingError = errors.New("Search Query is missing")
+var RevisionVersionMissingError = errors.New("Revision Version is invalid, please enter a value greater than 0")
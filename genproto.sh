@@ -3,10 +3,12 @@
 PATH=$PATH:$GOPATH/bin
 genpath=$(pwd)/genproto/.
 
+for name in posts apikeys; do
 protoc \
---proto_path=./protos/posts \
+--proto_path=./protos/$name \
 --go_out=$genpath \
---go_opt=Mcloudbees/protos/posts/posts.proto=cloudbees/genproto/posts \
+--go_opt=Mcloudbees/protos/$name/$name.proto=cloudbees/genproto/$name \
 --go-grpc_out=$genpath \
---go-grpc_opt=Mcloudbees/protos/posts/posts.proto=cloudbees/genproto/posts \
- ./protos/posts/posts.proto
+--go-grpc_opt=Mcloudbees/protos/$name/$name.proto=cloudbees/genproto/$name \
+ ./protos/$name/$name.proto
+done
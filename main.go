@@ -1,68 +1,305 @@
 package main
 
 import (
+	"cloudbees/auth"
+	"cloudbees/config"
 	dao "cloudbees/dao"
+	"cloudbees/genproto/apikeys"
 	postsGrpc "cloudbees/genproto/posts"
+	"cloudbees/metrics"
+	"cloudbees/ratelimit"
 	"cloudbees/services"
 	svc "cloudbees/services"
+	"cloudbees/tracing"
+	"cloudbees/transport"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 
 	"google.golang.org/grpc"
 )
 
 var postsService *svc.PostsService
+var apiKeyService *svc.ApiKeyService
 var logger *zap.Logger
 
+// maxPurgeInterval bounds how long expired posts can outlive their retention.
+const maxPurgeInterval = time.Hour
+
 type server struct {
 	server *grpc.Server
+	// apiKeys is set when the server serves the ApiKeyService.
+	apiKeys bool
+	metrics *metrics.ServerMetrics
 }
 
-func createServer() *server {
-	return &server{
-		server: grpc.NewServer(
-			grpc.UnaryInterceptor(loggingInterceptor),
-		),
+func createServer(cfg *config.Config) (*server, error) {
+	serverMetrics := metrics.NewServerMetrics()
+	interceptors := []grpc.UnaryServerInterceptor{tracing.UnaryServerInterceptor, serverMetrics.UnaryInterceptor}
+	// Shed calls are traced and counted, but not logged.
+	if cfg.Limits.MaxInFlight > 0 {
+		interceptors = append(interceptors, tracing.Interceptor("ConcurrencyLimit", ratelimit.NewConcurrencyLimit(cfg.Limits.MaxInFlight).UnaryInterceptor))
+	}
+	interceptors = append(interceptors, loggingInterceptor)
+	if cfg.Auth.Enabled() {
+		var apiKeys auth.ApiKeyVerifier
+		if cfg.Auth.ApiKeys {
+			apiKeys = apiKeyService
+		}
+		authenticator, err := newAuthenticator(cfg.Auth, apiKeys)
+		if err != nil {
+			return nil, err
+		}
+		authorizer := auth.NewAuthorizer(services.RequiredRoles)
+		interceptors = append(interceptors,
+			tracing.Interceptor("Authenticator", authenticator.UnaryInterceptor),
+			tracing.Interceptor("Authorizer", authorizer.UnaryInterceptor))
+	}
+	if cfg.Limits.ReadRate > 0 || cfg.Limits.WriteRate > 0 {
+		interceptors = append(interceptors, tracing.Interceptor("RateLimit", newRateLimit(cfg.Limits).UnaryInterceptor))
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+	if cfg.TLS.Enabled() {
+		certs, err := transport.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		certs.OnReload = func(err error) {
+			if err != nil {
+				logger.Error("cannot reload TLS files, still serving the previous ones", zap.Error(err))
+				return
+			}
+			logger.Info("Reloaded TLS files")
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.TLSConfig())))
 	}
+	if cfg.Limits.MaxRecvMsgBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.Limits.MaxRecvMsgBytes))
+	}
+	if cfg.Limits.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.Limits.MaxConcurrentStreams))
+	}
+	return &server{server: grpc.NewServer(opts...), apiKeys: cfg.Auth.ApiKeys, metrics: serverMetrics}, nil
 }
 
-func initPostsService(postsDao *dao.PostDAO) {
-	postsService = services.NewPostsService(postsDao)
+// newRateLimit returns the RateLimit of the configured read and write rates.
+func newRateLimit(cfg config.LimitsConfig) *ratelimit.RateLimit {
+	var reads, writes *ratelimit.Limiter
+	if cfg.ReadRate > 0 {
+		reads = ratelimit.NewLimiter(cfg.ReadRate, cfg.ReadBurst)
+	}
+	if cfg.WriteRate > 0 {
+		writes = ratelimit.NewLimiter(cfg.WriteRate, cfg.WriteBurst)
+	}
+	return ratelimit.NewRateLimit(reads, writes, services.IsReadMethod)
 }
 
-func init() {
-	postsDao := dao.NewPostDAO()
-	initPostsService(postsDao)
+// tracingShutdownTimeout bounds how long exporting the last spans may take
+// on shutdown.
+const tracingShutdownTimeout = 5 * time.Second
+
+// newTracerProvider returns the TracerProvider exporting traces as configured,
+// and a function flushing and closing it.
+func newTracerProvider(ctx context.Context, cfg config.TracingConfig) (*sdktrace.TracerProvider, func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch cfg.Exporter {
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		file, err = os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, nil, err
+	}
+	provider := tracing.NewTracerProvider(exporter, cfg.SampleRatio)
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}
+	return provider, shutdown, nil
+}
+
+// getPostMethod is the full name of the GetPost method.
+const getPostMethod = "/posts.BlogService/GetPost"
+
+// newAuthenticator returns the Authenticator of the configured ways to
+// authenticate, verifying API keys with apiKeys if not nil.
+func newAuthenticator(cfg config.AuthConfig, apiKeys auth.ApiKeyVerifier) (*auth.Authenticator, error) {
+	jwt := cfg.JWT
+	verifier, err := auth.NewJWTVerifier(jwt.HMACSecretFile, jwt.JWKSFile, jwt.Issuer, jwt.Audience)
+	if err != nil {
+		return nil, err
+	}
+	var anonymous []string
+	if cfg.AnonymousGetPost {
+		anonymous = append(anonymous, getPostMethod)
+	}
+	return auth.NewAuthenticator(verifier, apiKeys, anonymous...), nil
+}
+
+func initPostsService(blogs *dao.BlogRepository) {
+	postsService = services.NewBlogPostsService(blogs)
+}
+
+func initApiKeyService(keys dao.ApiKeyRepository) {
+	apiKeyService = services.NewApiKeyService(keys)
+}
+
+// newApiKeyRepository opens the API keys of the configured storage backend:
+// in memory, or in a JSON file next to the data file of the default blog.
+func newApiKeyRepository(storage config.StorageConfig) (dao.ApiKeyRepository, error) {
+	if storage.Backend == "memory" {
+		return dao.NewApiKeyDAO(), nil
+	}
+	ext := filepath.Ext(storage.Path)
+	return dao.NewFileApiKeyDAO(strings.TrimSuffix(storage.Path, ext) + ".apikeys.json")
+}
+
+// newBlogRepository opens the blogs of the configured storage backend.
+func newBlogRepository(storage config.StorageConfig) (*dao.BlogRepository, error) {
+	switch storage.Backend {
+	case "memory":
+		return dao.NewMemoryBlogRepository(), nil
+	case "file":
+		return dao.NewFileBlogRepository(storage.Path)
+	case "sqlite":
+		return dao.NewSQLiteBlogRepository(storage.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", storage.Backend)
+	}
+}
+
+// newLogger builds a production logger with the configured level and format.
+func newLogger(log config.LogConfig) (*zap.Logger, error) {
+	level, err := zap.ParseAtomicLevel(log.Level)
+	if err != nil {
+		return nil, err
+	}
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = level
+	zapConfig.Encoding = log.Format
+	return zapConfig.Build()
+}
 
+func init() {
 	logger, _ = zap.NewProduction()
 	defer logger.Sync()
 }
 
 func (s *server) registerService(service grpc.ServiceRegistrar) {
 	postsGrpc.RegisterBlogServiceServer(s.server, postsService)
+	if s.apiKeys {
+		apikeys.RegisterApiKeyServiceServer(s.server, apiKeyService)
+	}
 }
 
 func (s *server) serve(listener net.Listener) error {
 	return s.server.Serve(listener)
 }
 
-func (s *server) start(listener net.Listener) {
-	err := s.server.Serve(listener)
-	if err != nil {
-		logger.Sugar().Fatalf("cannot serve grpc server: %s", err)
+// shutdown stops accepting connections and waits up to timeout for in-flight
+// calls to finish, then cancels the ones left. It reports whether every call
+// finished in time.
+func (s *server) shutdown(timeout time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(drained)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return true
+	case <-timer.C:
+		s.server.Stop()
+		<-drained
+		return false
 	}
 }
 
+// newMetricsRegistry returns the registry of the metrics of the server's
+// calls, of the storage of blogs, and of the Go runtime and process.
+func newMetricsRegistry(s *server, blogs *dao.BlogRepository) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(s.metrics, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	registry.MustRegister(dao.Collectors(blogs)...)
+	return registry
+}
+
+// newAdminServer returns the HTTP server of the admin endpoints: the metrics
+// of registry at /metrics.
+func newAdminServer(registry *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
+
+// secretResponses are the methods whose responses hold secrets, which are
+// not logged.
+var secretResponses = map[string]bool{
+	"/apikeys.ApiKeyService/CreateApiKey": true,
+}
+
 // loggingInterceptor  logs the request and response.
 func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	logger.Info("gRPC method", zap.String("method", info.FullMethod), zap.Any("request", req))
+	fields := []zap.Field{zap.String("method", info.FullMethod), zap.Any("request", req)}
+	if client, ok := transport.ClientIdentityFromContext(ctx); ok {
+		fields = append(fields, zap.String("client", client.Name()))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields = append(fields, zap.String("trace_id", span.TraceID().String()))
+	}
+	logger.Info("gRPC method", fields...)
 	resp, err := handler(ctx, req)
 	if err != nil {
 		st, _ := status.FromError(err)
 		logger.Error("gRPC method", zap.String("method", info.FullMethod), zap.Error(err), zap.String("code", st.Code().String()))
+	} else if secretResponses[info.FullMethod] {
+		logger.Info("gRPC method", zap.String("method", info.FullMethod))
 	} else {
 		logger.Info("gRPC method", zap.String("method", info.FullMethod), zap.Any("response", resp))
 	}
@@ -70,18 +307,142 @@ func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySe
 }
 
 func main() {
-	logger, _ = zap.NewProduction()
-	defer logger.Sync()
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
+
+	logger, err = newLogger(cfg.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %s\n", err)
+		os.Exit(2)
+	}
 
-	listener, err := net.Listen("tcp", ":80")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = run(ctx, cfg)
+	stop()
 	if err != nil {
-		logger.Sugar().Fatalf("cannot create Listener: %s", err)
+		logger.Error("server failed", zap.Error(err))
 	}
-	defer listener.Close()
+	// Syncing fails harmlessly on terminals, so its error is ignored.
+	_ = logger.Sync()
+	if err != nil {
+		os.Exit(1)
+	}
+}
 
-	logger.Info("Server started on port 80")
+// run serves until ctx is done, then drains in-flight calls, stops the
+// purger and closes the post storage.
+func run(ctx context.Context, cfg *config.Config) error {
+	// Traces are flushed last, after the spans of closing the storage.
+	if cfg.Tracing.Enabled() {
+		provider, shutdownTracing, err := newTracerProvider(ctx, cfg.Tracing)
+		if err != nil {
+			return fmt.Errorf("cannot start tracing: %w", err)
+		}
+		otel.SetTracerProvider(provider)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Error("cannot flush traces", zap.Error(err))
+			}
+		}()
+	}
 
-	s := createServer()
+	blogs, err := newBlogRepository(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("cannot open post storage: %w", err)
+	}
+	defer func() {
+		if err := blogs.Close(); err != nil {
+			logger.Error("cannot close post storage", zap.Error(err))
+			return
+		}
+		logger.Info("Closed post storage")
+	}()
+	initPostsService(blogs)
+	if cfg.Auth.ApiKeys {
+		keys, err := newApiKeyRepository(cfg.Storage)
+		if err != nil {
+			return fmt.Errorf("cannot open API key storage: %w", err)
+		}
+		initApiKeyService(keys)
+	}
+
+	s, err := createServer(cfg)
+	if err != nil {
+		return err
+	}
 	s.registerService(s.server)
-	s.start(listener)
+
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("cannot create Listener: %w", err)
+	}
+
+	// The admin endpoints outlive the gRPC server, so the drain can be watched.
+	if cfg.Admin.Listen != "" {
+		adminListener, err := net.Listen("tcp", cfg.Admin.Listen)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("cannot create admin Listener: %w", err)
+		}
+		admin := newAdminServer(newMetricsRegistry(s, blogs))
+		defer admin.Close()
+		go func() {
+			if err := admin.Serve(adminListener); !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("cannot serve admin endpoints", zap.Error(err))
+			}
+		}()
+		logger.Info("Admin endpoints started", zap.String("listen", cfg.Admin.Listen))
+	}
+
+	// The purger is stopped before the storage it purges is closed.
+	purgerCtx, stopPurger := context.WithCancel(context.Background())
+	purgerDone := make(chan struct{})
+	defer func() {
+		stopPurger()
+		<-purgerDone
+		logger.Info("Stopped trash purger")
+	}()
+	trashRetention := time.Duration(cfg.Storage.TrashRetention)
+	purgeInterval := trashRetention
+	if purgeInterval > maxPurgeInterval {
+		purgeInterval = maxPurgeInterval
+	}
+	go func() {
+		defer close(purgerDone)
+		postsService.RunPurger(purgerCtx, trashRetention, purgeInterval, func(err error) {
+			logger.Error("cannot purge deleted posts", zap.Error(err))
+		})
+	}()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serve(listener)
+	}()
+	if !cfg.Auth.Enabled() {
+		logger.Warn("Authentication is disabled, anyone who can reach the server can change posts")
+	}
+	logger.Info("Server started", zap.String("listen", cfg.Listen), zap.Bool("tls", cfg.TLS.Enabled()), zap.String("tracing", cfg.Tracing.Exporter), zap.Bool("mtls", cfg.TLS.ClientCAFile != ""), zap.String("storage", cfg.Storage.Backend))
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("cannot serve grpc server: %w", err)
+	case <-ctx.Done():
+	}
+
+	drainTimeout := time.Duration(cfg.DrainTimeout)
+	logger.Info("Shutting down, draining in-flight calls", zap.Duration("drain_timeout", drainTimeout))
+	if s.shutdown(drainTimeout) {
+		logger.Info("Drained all calls")
+	} else {
+		logger.Warn("Drain timeout exceeded, cancelled the remaining calls")
+	}
+	return <-served
 }
@@ -1,26 +1,43 @@
 package main
 
 import (
+	"bytes"
+	"cloudbees/config"
 	"cloudbees/dao"
 	e "cloudbees/errors"
+	"cloudbees/genproto/apikeys"
 	"cloudbees/genproto/posts"
 	"cloudbees/services"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func setupServer() (*grpc.Server, *net.Listener) {
 	server := grpc.NewServer()
+	// Every test gets a store of its own.
 	postsDao := dao.NewPostDAO()
 	postsService := services.NewPostsService(postsDao)
 	posts.RegisterBlogServiceServer(server, postsService)
-	listen, err := net.Listen("tcp", "localhost:8080")
+	listen, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
 		panic(fmt.Errorf("failed to listen: %v", err))
 	}
@@ -40,10 +57,27 @@ func setupClient(serverAddress string) posts.BlogServiceClient {
 	return posts.NewBlogServiceClient(conn)
 }
 
+// seedPost creates the post with id 1 that the read, update and delete tests
+// work on.
+func seedPost(t *testing.T, client posts.BlogServiceClient) {
+	t.Helper()
+	_, err := client.CreatePost(context.Background(), &posts.CreatePostRequest{
+		PostId:          1,
+		Title:           "Test Post",
+		Content:         "Test Content",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test", "integration"},
+	})
+	if err != nil {
+		t.Fatalf("failed to seed post: %v", err)
+	}
+}
+
 func TestCreatePostIntegration(t *testing.T) {
 	server, listen := setupServer()
 
-	serverAddress := "localhost:8080"
+	serverAddress := (*listen).Addr().String()
 	client := setupClient(serverAddress)
 
 	testCases := []struct {
@@ -63,6 +97,18 @@ func TestCreatePostIntegration(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name: "Error scenario: duplicate post id",
+			request: &posts.CreatePostRequest{
+				PostId:          1,
+				Title:           "Another Test Post",
+				Content:         "Test Content",
+				Author:          "Another Test Author",
+				PublicationDate: "01-01-2024",
+				Tags:            []string{"test"},
+			},
+			expected: status.Error(codes.AlreadyExists, e.EntityAlreadyExistsError.Error()),
+		},
 		{
 			name: "Validation error scenario: empty title",
 			request: &posts.CreatePostRequest{
@@ -155,8 +201,9 @@ func TestCreatePostIntegration(t *testing.T) {
 func TestReadPostIntegration(t *testing.T) {
 	server, listen := setupServer()
 
-	serverAddress := "localhost:8080"
+	serverAddress := (*listen).Addr().String()
 	client := setupClient(serverAddress)
+	seedPost(t, client)
 
 	testCases := []struct {
 		name     string
@@ -198,8 +245,9 @@ func TestReadPostIntegration(t *testing.T) {
 func TestUpdatePostIntegration(t *testing.T) {
 	server, listen := setupServer()
 
-	serverAddress := "localhost:8080"
+	serverAddress := (*listen).Addr().String()
 	client := setupClient(serverAddress)
+	seedPost(t, client)
 
 	updateRequest := &posts.UpdatePostRequest{
 		PostId:          1,
@@ -227,6 +275,30 @@ func TestUpdatePostIntegration(t *testing.T) {
 		t.Fatalf("updated post does not match expected post")
 	}
 
+	versionedPost, err := client.UpdatePost(context.Background(), &posts.UpdatePostRequest{
+		PostId:          1,
+		ExpectedVersion: updatedPost.Version,
+	})
+	if err != nil {
+		t.Fatalf("failed to update post at expected version: %v", err)
+	}
+	if versionedPost.Version != updatedPost.Version+1 {
+		t.Fatalf("expected version %d, got %d", updatedPost.Version+1, versionedPost.Version)
+	}
+
+	maskedPost, err := client.UpdatePost(context.Background(), &posts.UpdatePostRequest{
+		PostId:     1,
+		Title:      "Masked Test Post",
+		Content:    "Unmasked Test Content",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title", "tags"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update post with update mask: %v", err)
+	}
+	if maskedPost.Title != "Masked Test Post" || maskedPost.Content != updatedPost.Content || len(maskedPost.Tags) != 0 {
+		t.Fatalf("update mask not applied as expected: %v", maskedPost)
+	}
+
 	testCases := []struct {
 		name     string
 		request  *posts.UpdatePostRequest
@@ -256,6 +328,31 @@ func TestUpdatePostIntegration(t *testing.T) {
 			},
 			expected: status.Error(codes.InvalidArgument, e.InvalidPublicationDateError.Error()),
 		},
+		{
+			name: "Error scenario: stale expected version",
+			request: &posts.UpdatePostRequest{
+				PostId:          1,
+				Title:           "Concurrently Updated Test Post",
+				ExpectedVersion: updatedPost.Version - 1,
+			},
+			expected: status.Error(codes.Aborted, e.VersionConflictError.Error()),
+		},
+		{
+			name: "Error scenario: unknown update mask path",
+			request: &posts.UpdatePostRequest{
+				PostId:     1,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"likes"}},
+			},
+			expected: status.Error(codes.InvalidArgument, e.InvalidUpdateMaskError.Error()+`: unknown field "likes"`),
+		},
+		{
+			name: "Error scenario: masked required field cleared",
+			request: &posts.UpdatePostRequest{
+				PostId:     1,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"content"}},
+			},
+			expected: status.Error(codes.InvalidArgument, e.ContentMissingError.Error()),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -304,13 +401,24 @@ func isPostEqual(post1, post2 *posts.PostResponse) bool {
 func TestDeletePostIntegration(t *testing.T) {
 	server, listen := setupServer()
 
-	serverAddress := "localhost:8080"
+	serverAddress := (*listen).Addr().String()
 	client := setupClient(serverAddress)
+	seedPost(t, client)
+
+	// The seeded post is still at version 1.
+	staleDeleteRequest := &posts.DeletePostRequest{
+		PostId:          1,
+		ExpectedVersion: 2,
+	}
+	_, err := client.DeletePost(context.Background(), staleDeleteRequest)
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("expected %v deleting stale version, got: %v", codes.Aborted, err)
+	}
 
 	deleteRequest := &posts.DeletePostRequest{
 		PostId: 1,
 	}
-	_, err := client.DeletePost(context.Background(), deleteRequest)
+	_, err = client.DeletePost(context.Background(), deleteRequest)
 	if err != nil {
 		t.Fatalf("failed to delete post: %v", err)
 	}
@@ -327,3 +435,855 @@ func TestDeletePostIntegration(t *testing.T) {
 	server.Stop()
 	(*listen).Close()
 }
+
+func TestListPostsIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+
+	seed := []*posts.CreatePostRequest{
+		{PostId: 101, Title: "Go", Content: "Content", Author: "alice", PublicationDate: "03-01-2024", Tags: []string{"go"}},
+		{PostId: 102, Title: "Rust", Content: "Content", Author: "bob", PublicationDate: "01-01-2024", Tags: []string{"rust"}},
+		{PostId: 103, Title: "Zig", Content: "Content", Author: "alice", PublicationDate: "02-01-2024", Tags: []string{"go", "zig"}},
+		{PostId: 104, Title: "C", Content: "Content", Author: "alice", PublicationDate: "05-02-2024", Tags: []string{"c"}},
+	}
+	for _, request := range seed {
+		if _, err := client.CreatePost(context.Background(), request); err != nil {
+			t.Fatalf("failed to create post: %v", err)
+		}
+	}
+
+	listIds := func(request *posts.ListPostsRequest) []uint64 {
+		ids := []uint64{}
+		for {
+			response, err := client.ListPosts(context.Background(), request)
+			if err != nil {
+				t.Fatalf("failed to list posts: %v", err)
+			}
+			for _, post := range response.Posts {
+				ids = append(ids, post.PostId)
+			}
+			if response.NextPageToken == "" {
+				return ids
+			}
+			request.PageToken = response.NextPageToken
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		request  *posts.ListPostsRequest
+		expected []uint64
+	}{
+		{
+			name:     "Paginated by post id",
+			request:  &posts.ListPostsRequest{PageSize: 1},
+			expected: []uint64{101, 102, 103, 104},
+		},
+		{
+			name:     "Filtered by author",
+			request:  &posts.ListPostsRequest{PageSize: 2, Author: "alice"},
+			expected: []uint64{101, 103, 104},
+		},
+		{
+			name:     "Filtered by tag",
+			request:  &posts.ListPostsRequest{Tag: "go"},
+			expected: []uint64{101, 103},
+		},
+		{
+			name:     "Filtered by publication date range",
+			request:  &posts.ListPostsRequest{PublicationDateFrom: "02-01-2024", PublicationDateTo: "31-01-2024"},
+			expected: []uint64{101, 103},
+		},
+		{
+			name:     "Ordered by publication date descending",
+			request:  &posts.ListPostsRequest{PageSize: 3, OrderBy: "publication_date desc"},
+			expected: []uint64{104, 101, 103, 102},
+		},
+		{
+			name:     "Ordered by title",
+			request:  &posts.ListPostsRequest{PageSize: 2, OrderBy: "title"},
+			expected: []uint64{104, 101, 102, 103},
+		},
+		{
+			name:     "Ordered by post id descending",
+			request:  &posts.ListPostsRequest{PageSize: 3, OrderBy: "post_id desc", Author: "alice"},
+			expected: []uint64{104, 103, 101},
+		},
+		{
+			name:     "Ordered by author descending",
+			request:  &posts.ListPostsRequest{PageSize: 1, OrderBy: "author desc"},
+			expected: []uint64{102, 104, 103, 101},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ids := listIds(tc.request)
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		name     string
+		request  *posts.ListPostsRequest
+		expected error
+	}{
+		{
+			name:     "Error scenario: invalid order by",
+			request:  &posts.ListPostsRequest{OrderBy: "likes"},
+			expected: status.Error(codes.InvalidArgument, e.InvalidOrderByError.Error()),
+		},
+		{
+			name:     "Error scenario: invalid page token",
+			request:  &posts.ListPostsRequest{PageToken: "not-a-token"},
+			expected: status.Error(codes.InvalidArgument, e.InvalidPageTokenError.Error()),
+		},
+		{
+			name:     "Error scenario: inverted date range",
+			request:  &posts.ListPostsRequest{PublicationDateFrom: "02-01-2024", PublicationDateTo: "01-01-2024"},
+			expected: status.Error(codes.InvalidArgument, e.InvalidDateRangeError.Error()),
+		},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.ListPosts(context.Background(), tc.request)
+			if fmt.Sprint(err) != fmt.Sprint(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+func TestCreatePostGeneratedIdIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+
+	request := &posts.CreatePostRequest{
+		Title:           "Test Post",
+		Content:         "Test Content",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	}
+	first, err := client.CreatePost(context.Background(), request)
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	second, err := client.CreatePost(context.Background(), request)
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	if first.PostId == 0 || second.PostId <= first.PostId {
+		t.Fatalf("expected increasing generated ids, got %d and %d", first.PostId, second.PostId)
+	}
+
+	fetched, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: second.PostId})
+	if err != nil {
+		t.Fatalf("failed to read post with generated id: %v", err)
+	}
+	if !isPostEqual(fetched, second) {
+		t.Fatalf("read post does not match created post")
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+func TestSearchPostsIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+
+	created, err := client.CreatePost(context.Background(), &posts.CreatePostRequest{
+		Title:           "Full-text search",
+		Content:         "Posts can be found by the words in their title, content and tags.",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"search"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	response, err := client.SearchPosts(context.Background(), &posts.SearchPostsRequest{Query: `"full text" word*`})
+	if err != nil {
+		t.Fatalf("failed to search posts: %v", err)
+	}
+	if len(response.Results) != 1 || response.Results[0].Post.PostId != created.PostId {
+		t.Fatalf("expected to find post %d, got %v", created.PostId, response.Results)
+	}
+	result := response.Results[0]
+	if len(result.Highlights) != 1 || result.Snippet[result.Highlights[0].Start:result.Highlights[0].End] != "words" {
+		t.Fatalf("expected words to be highlighted in %q, got %v", result.Snippet, result.Highlights)
+	}
+
+	_, err = client.SearchPosts(context.Background(), &posts.SearchPostsRequest{Query: " "})
+	expectedErr := status.Error(codes.InvalidArgument, e.SearchQueryMissingError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected error: %v, got: %v", expectedErr, err)
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+func TestPostRevisionsIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "Test Editor")
+	created, err := client.CreatePost(ctx, &posts.CreatePostRequest{
+		Title:           "Revisions",
+		Content:         "First line\nSecond line\n",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"history"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	_, err = client.UpdatePost(ctx, &posts.UpdatePostRequest{
+		PostId:     created.PostId,
+		Content:    "First line\nChanged line\n",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"content"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+
+	list, err := client.ListPostRevisions(context.Background(), &posts.ListPostRevisionsRequest{PostId: created.PostId, PageSize: 1})
+	if err != nil {
+		t.Fatalf("failed to list revisions: %v", err)
+	}
+	if len(list.Revisions) != 1 || list.Revisions[0].Version != 2 || list.NextPageToken == "" {
+		t.Fatalf("expected newest revision first with a next page, got %v", list)
+	}
+	if list.Revisions[0].UpdatedBy != "Test Editor" || fmt.Sprint(list.Revisions[0].ChangedFields) != "[content]" {
+		t.Fatalf("unexpected revision metadata %v", list.Revisions[0])
+	}
+	list, err = client.ListPostRevisions(context.Background(), &posts.ListPostRevisionsRequest{PostId: created.PostId, PageToken: list.NextPageToken})
+	if err != nil || len(list.Revisions) != 1 || list.Revisions[0].Version != 1 || list.NextPageToken != "" {
+		t.Fatalf("expected the first revision on the last page, got %v, %v", list, err)
+	}
+
+	diff, err := client.DiffPostRevisions(context.Background(), &posts.DiffPostRevisionsRequest{PostId: created.PostId, FromVersion: 1, ToVersion: 2})
+	if err != nil {
+		t.Fatalf("failed to diff revisions: %v", err)
+	}
+	expectedDiff := "--- revision 1\n+++ revision 2\n@@ -1,2 +1,2 @@\n First line\n-Second line\n+Changed line\n"
+	if diff.Diff != expectedDiff {
+		t.Fatalf("expected diff:\n%s\ngot:\n%s", expectedDiff, diff.Diff)
+	}
+
+	reverted, err := client.RevertPost(ctx, &posts.RevertPostRequest{PostId: created.PostId, Version: 1, ExpectedVersion: 2})
+	if err != nil {
+		t.Fatalf("failed to revert post: %v", err)
+	}
+	if reverted.Content != created.Content || reverted.Version != 3 {
+		t.Fatalf("expected revision 1 content at version 3, got %v", reverted)
+	}
+
+	_, err = client.GetPostRevision(context.Background(), &posts.GetPostRevisionRequest{PostId: created.PostId, Version: 4})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected %v for a missing revision, got %v", codes.NotFound, err)
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+func TestTrashIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+
+	created, err := client.CreatePost(context.Background(), &posts.CreatePostRequest{
+		Title:           "Trash",
+		Content:         "Deleted posts can be restored until they are purged.",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"trash"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "Test Editor")
+	if _, err := client.DeletePost(ctx, &posts.DeletePostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("failed to delete post: %v", err)
+	}
+	_, err = client.GetPost(context.Background(), &posts.GetPostRequest{PostId: created.PostId})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected %v getting a deleted post, got %v", codes.NotFound, err)
+	}
+
+	trashed := findDeletedPost(t, client, created.PostId)
+	if trashed == nil || trashed.DeletedBy != "Test Editor" || trashed.DeleteTime == nil {
+		t.Fatalf("expected post %d in the trash, got %v", created.PostId, trashed)
+	}
+
+	restored, err := client.RestorePost(context.Background(), &posts.RestorePostRequest{PostId: created.PostId})
+	if err != nil || restored.Title != created.Title || restored.DeleteTime != nil {
+		t.Fatalf("expected restored post, got %v, %v", restored, err)
+	}
+	if _, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("failed to get restored post: %v", err)
+	}
+
+	_, err = client.PurgePost(context.Background(), &posts.PurgePostRequest{PostId: created.PostId})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected %v purging a post not in the trash, got %v", codes.NotFound, err)
+	}
+	client.DeletePost(context.Background(), &posts.DeletePostRequest{PostId: created.PostId})
+	if _, err := client.PurgePost(context.Background(), &posts.PurgePostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("failed to purge post: %v", err)
+	}
+	if findDeletedPost(t, client, created.PostId) != nil {
+		t.Fatalf("expected purged post to leave the trash")
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+// findDeletedPost pages through the trash looking for the post with the given id.
+func findDeletedPost(t *testing.T, client posts.BlogServiceClient, id uint64) *posts.PostResponse {
+	t.Helper()
+	request := &posts.ListDeletedPostsRequest{PageSize: 1}
+	for {
+		response, err := client.ListDeletedPosts(context.Background(), request)
+		if err != nil {
+			t.Fatalf("failed to list deleted posts: %v", err)
+		}
+		for _, post := range response.Posts {
+			if post.PostId == id {
+				return post
+			}
+		}
+		if response.NextPageToken == "" {
+			return nil
+		}
+		request.PageToken = response.NextPageToken
+	}
+}
+
+func TestBlogsIntegration(t *testing.T) {
+	server, listen := setupServer()
+
+	serverAddress := (*listen).Addr().String()
+	client := setupClient(serverAddress)
+	seedPost(t, client)
+
+	if _, err := client.CreateBlog(context.Background(), &posts.CreateBlogRequest{BlogId: "team-a"}); err != nil {
+		t.Fatalf("failed to create blog: %v", err)
+	}
+	_, err := client.CreateBlog(context.Background(), &posts.CreateBlogRequest{BlogId: "Team A"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected %v creating blog with an invalid id, got %v", codes.InvalidArgument, err)
+	}
+
+	teamA := metadata.AppendToOutgoingContext(context.Background(), "x-blog", "team-a")
+	created, err := client.CreatePost(teamA, &posts.CreatePostRequest{
+		Title:           "Team A Post",
+		Content:         "Posted to another blog.",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"team"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	if created.PostId != 1 {
+		t.Fatalf("expected the blog's own first post id 1, got %d", created.PostId)
+	}
+	if post, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: 1}); err != nil || post.Title != "Test Post" {
+		t.Fatalf("expected the default blog's post 1 to be unchanged, got %v, %v", post, err)
+	}
+
+	list, err := client.ListPosts(teamA, &posts.ListPostsRequest{})
+	if err != nil || len(list.Posts) != 1 || list.Posts[0].Title != "Team A Post" {
+		t.Fatalf("expected to list only the blog's post, got %v, %v", list, err)
+	}
+	search, err := client.SearchPosts(context.Background(), &posts.SearchPostsRequest{Query: "another"})
+	if err != nil || len(search.Results) != 0 {
+		t.Fatalf("expected the default blog's search to skip other blogs, got %v, %v", search, err)
+	}
+
+	blogs, err := client.ListBlogs(context.Background(), &posts.ListBlogsRequest{})
+	if err != nil || len(blogs.Blogs) != 2 || blogs.Blogs[1].BlogId != "team-a" {
+		t.Fatalf("expected default and team-a blogs, got %v, %v", blogs, err)
+	}
+	if _, err := client.DeleteBlog(context.Background(), &posts.DeleteBlogRequest{BlogId: "team-a"}); err != nil {
+		t.Fatalf("failed to delete blog: %v", err)
+	}
+	_, err = client.GetPost(teamA, &posts.GetPostRequest{PostId: 1})
+	expectedErr := status.Error(codes.NotFound, e.BlogNotFoundError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected error: %v, got: %v", expectedErr, err)
+	}
+	_, err = client.DeleteBlog(context.Background(), &posts.DeleteBlogRequest{BlogId: "default"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected %v deleting the default blog, got %v", codes.FailedPrecondition, err)
+	}
+
+	server.Stop()
+	(*listen).Close()
+}
+
+// startBlockingServer serves posts with calls that block until release is
+// closed, signalling entered as each call starts.
+func startBlockingServer(t *testing.T, entered chan<- struct{}, release <-chan struct{}) (*server, <-chan error, string) {
+	t.Helper()
+	block := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		entered <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return handler(ctx, req)
+	}
+	s := &server{server: grpc.NewServer(grpc.UnaryInterceptor(block))}
+	posts.RegisterBlogServiceServer(s.server, services.NewPostsService(dao.NewPostDAO()))
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serve(listen)
+	}()
+	return s, served, listen.Addr().String()
+}
+
+func TestShutdownDrainsInFlightCalls(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	s, served, address := startBlockingServer(t, entered, release)
+	client := setupClient(address)
+
+	called := make(chan error, 1)
+	go func() {
+		_, err := client.ListPosts(context.Background(), &posts.ListPostsRequest{})
+		called <- err
+	}()
+	<-entered
+
+	drained := make(chan bool, 1)
+	go func() {
+		drained <- s.shutdown(10 * time.Second)
+	}()
+	close(release)
+	if !<-drained {
+		t.Fatalf("expected the in-flight call to be drained")
+	}
+	if err := <-called; err != nil {
+		t.Fatalf("expected the in-flight call to succeed, got %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("expected serve to return cleanly, got %v", err)
+	}
+}
+
+func TestShutdownCancelsCallsAfterDrainTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s, served, address := startBlockingServer(t, entered, release)
+	client := setupClient(address)
+
+	called := make(chan error, 1)
+	go func() {
+		_, err := client.ListPosts(context.Background(), &posts.ListPostsRequest{})
+		called <- err
+	}()
+	<-entered
+
+	if s.shutdown(50 * time.Millisecond) {
+		t.Fatalf("expected the drain timeout to be exceeded")
+	}
+	if err := <-called; status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected %v for the cancelled call, got %v", codes.Unavailable, err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("expected serve to return cleanly, got %v", err)
+	}
+}
+
+// testSecret signs the bearer tokens of the authentication tests.
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// setupAuthServer serves the posts service the way main does with the auth
+// settings and HS256 bearer tokens signed by testSecret, returning its address.
+func setupAuthServer(t *testing.T, authConfig config.AuthConfig) string {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth = authConfig
+	cfg.Auth.JWT.HMACSecretFile = filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(cfg.Auth.JWT.HMACSecretFile, []byte(testSecret), 0o600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+	initPostsService(dao.NewMemoryBlogRepository())
+	initApiKeyService(dao.NewApiKeyDAO())
+	s, err := createServer(cfg)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.registerService(s.server)
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.serve(listen)
+	t.Cleanup(s.server.Stop)
+	return listen.Addr().String()
+}
+
+// as returns a context authenticating calls as the subject with the roles.
+func as(t *testing.T, subject string, roles ...string) context.Context {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   subject,
+		"roles": roles,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuthenticationIntegration(t *testing.T) {
+	client := setupClient(setupAuthServer(t, config.AuthConfig{AnonymousGetPost: true}))
+
+	request := &posts.CreatePostRequest{
+		PostId:          1,
+		Title:           "Test Post",
+		Content:         "Test Content",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	}
+	_, err := client.CreatePost(context.Background(), request)
+	expectedErr := status.Error(codes.Unauthenticated, e.AuthenticationMissingError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected error: %v, got: %v", expectedErr, err)
+	}
+	if _, err := client.CreatePost(as(t, "alice", "author"), request); err != nil {
+		t.Fatalf("expected an authenticated CreatePost to succeed, got %v", err)
+	}
+
+	if _, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: 1}); err != nil {
+		t.Fatalf("expected an anonymous GetPost to succeed, got %v", err)
+	}
+	_, err = client.ListPosts(context.Background(), &posts.ListPostsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected an anonymous ListPosts to fail with %v, got %v", codes.Unauthenticated, err)
+	}
+}
+
+func TestAuthorizationIntegration(t *testing.T) {
+	client := setupClient(setupAuthServer(t, config.AuthConfig{}))
+	alice, bob := as(t, "alice", "author"), as(t, "bob", "author")
+
+	created, err := client.CreatePost(alice, &posts.CreatePostRequest{
+		Title:           "Alice's Post",
+		Content:         "Content",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	if created.Author != "alice" || created.UpdatedBy != "alice" {
+		t.Fatalf("expected the post to be written by alice, got author %q updated by %q", created.Author, created.UpdatedBy)
+	}
+	_, err = client.CreatePost(alice, &posts.CreatePostRequest{
+		Title:           "Bob's Post",
+		Content:         "Content",
+		Author:          "bob",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	})
+	expectedErr := status.Error(codes.PermissionDenied, e.NotPostAuthorError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected error: %v, got: %v", expectedErr, err)
+	}
+
+	_, err = client.CreatePost(as(t, "carol"), &posts.CreatePostRequest{
+		Title:           "Reader's Post",
+		Content:         "Content",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	})
+	expectedErr = status.Error(codes.PermissionDenied, e.PermissionDeniedError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected a reader's CreatePost to fail with: %v, got: %v", expectedErr, err)
+	}
+	if _, err := client.GetPost(as(t, "carol"), &posts.GetPostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("expected a reader's GetPost to succeed, got %v", err)
+	}
+
+	update := &posts.UpdatePostRequest{PostId: created.PostId, Title: "Edited"}
+	if _, err := client.UpdatePost(bob, update); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected bob's update of alice's post to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.DeletePost(bob, &posts.DeletePostRequest{PostId: created.PostId}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected bob's delete of alice's post to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	giveAway := &posts.UpdatePostRequest{PostId: created.PostId, Author: "bob"}
+	if _, err := client.UpdatePost(alice, giveAway); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected alice's change of the author to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.UpdatePost(alice, update); err != nil {
+		t.Fatalf("expected alice to update her post, got %v", err)
+	}
+	edited, err := client.UpdatePost(as(t, "erin", "editor"), giveAway)
+	if err != nil || edited.Author != "bob" {
+		t.Fatalf("expected an editor to reassign the post, got %v, %v", edited, err)
+	}
+
+	if _, err := client.DeletePost(alice, &posts.DeletePostRequest{PostId: created.PostId}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected alice's delete of bob's post to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.DeletePost(bob, &posts.DeletePostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("expected bob to delete his post, got %v", err)
+	}
+	if _, err := client.RestorePost(alice, &posts.RestorePostRequest{PostId: created.PostId}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected alice's restore of bob's post to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.PurgePost(bob, &posts.PurgePostRequest{PostId: created.PostId}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected an author's purge to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.CreateBlog(as(t, "erin", "editor"), &posts.CreateBlogRequest{BlogId: "team"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected an editor's CreateBlog to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := client.CreateBlog(as(t, "root", "admin"), &posts.CreateBlogRequest{BlogId: "team"}); err != nil {
+		t.Fatalf("expected an admin to create a blog, got %v", err)
+	}
+}
+
+func TestApiKeysIntegration(t *testing.T) {
+	conn, err := grpc.Dial(setupAuthServer(t, config.AuthConfig{ApiKeys: true}), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer conn.Close()
+	client, keys := posts.NewBlogServiceClient(conn), apikeys.NewApiKeyServiceClient(conn)
+	alice := as(t, "alice", "author")
+
+	writeKey, err := keys.CreateApiKey(alice, &apikeys.CreateApiKeyRequest{Name: "import job", Scope: apikeys.Scope_SCOPE_WRITE})
+	if err != nil {
+		t.Fatalf("failed to create write key: %v", err)
+	}
+	readKey, err := keys.CreateApiKey(alice, &apikeys.CreateApiKeyRequest{Name: "dashboard", Scope: apikeys.Scope_SCOPE_READ})
+	if err != nil {
+		t.Fatalf("failed to create read key: %v", err)
+	}
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	created, err := client.CreatePost(withKey(writeKey.Key), &posts.CreatePostRequest{
+		Title:           "Imported Post",
+		Content:         "Content",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"import"},
+	})
+	if err != nil || created.Author != "alice" {
+		t.Fatalf("expected the write key to create a post as alice, got %v, %v", created, err)
+	}
+	if _, err := client.GetPost(withKey(readKey.Key), &posts.GetPostRequest{PostId: created.PostId}); err != nil {
+		t.Fatalf("expected the read key to read posts, got %v", err)
+	}
+	_, err = client.DeletePost(withKey(readKey.Key), &posts.DeletePostRequest{PostId: created.PostId})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the read key's delete to fail with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := keys.ListApiKeys(withKey(writeKey.Key), &apikeys.ListApiKeysRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected keys to be refused managing keys with %v, got %v", codes.PermissionDenied, err)
+	}
+
+	if _, err := keys.RevokeApiKey(alice, &apikeys.RevokeApiKeyRequest{KeyId: writeKey.ApiKey.KeyId}); err != nil {
+		t.Fatalf("failed to revoke key: %v", err)
+	}
+	_, err = client.GetPost(withKey(writeKey.Key), &posts.GetPostRequest{PostId: created.PostId})
+	expectedErr := status.Error(codes.Unauthenticated, e.InvalidApiKeyError.Error())
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Fatalf("expected error: %v, got: %v", expectedErr, err)
+	}
+	list, err := keys.ListApiKeys(alice, &apikeys.ListApiKeysRequest{})
+	if err != nil || len(list.ApiKeys) != 2 || list.ApiKeys[0].RevokeTime == nil || list.ApiKeys[1].LastUsedTime == nil {
+		t.Fatalf("expected the revoked write key and the used read key, got %v, %v", list, err)
+	}
+}
+
+func TestRateLimitIntegration(t *testing.T) {
+	cfg := config.Default()
+	cfg.Limits.WriteRate, cfg.Limits.WriteBurst = 0.001, 2
+	initPostsService(dao.NewMemoryBlogRepository())
+	s, err := createServer(cfg)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.registerService(s.server)
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.serve(listen)
+	t.Cleanup(s.server.Stop)
+	client := setupClient(listen.Addr().String())
+
+	create := func(header *metadata.MD) error {
+		_, err := client.CreatePost(context.Background(), &posts.CreatePostRequest{
+			Title:           "Test Post",
+			Content:         "Test Content",
+			Author:          "alice",
+			PublicationDate: "01-01-2024",
+			Tags:            []string{"test"},
+		}, grpc.Header(header))
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := create(&metadata.MD{}); err != nil {
+			t.Fatalf("expected write %d of the burst to succeed, got %v", i+1, err)
+		}
+	}
+	var header metadata.MD
+	err = create(&header)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected %v once the burst is spent, got %v", codes.ResourceExhausted, err)
+	}
+	if retryAfter := header.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] == "" {
+		t.Fatalf("expected retry-after metadata, got %v", header)
+	}
+	if _, err := client.ListPosts(context.Background(), &posts.ListPostsRequest{}); err != nil {
+		t.Fatalf("expected reads to have their own budget, got %v", err)
+	}
+}
+
+func TestMetricsIntegration(t *testing.T) {
+	blogs := dao.NewMemoryBlogRepository()
+	initPostsService(blogs)
+	s, err := createServer(config.Default())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.registerService(s.server)
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.serve(listen)
+	t.Cleanup(s.server.Stop)
+	admin := httptest.NewServer(newAdminServer(newMetricsRegistry(s, blogs)).Handler)
+	t.Cleanup(admin.Close)
+	client := setupClient(listen.Addr().String())
+
+	seedPost(t, client)
+	if _, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: 42}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected %v for a missing post, got %v", codes.NotFound, err)
+	}
+
+	response, err := http.Get(admin.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to scrape metrics: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	for _, want := range []string{
+		`blog_grpc_requests_total{code="OK",method="/posts.BlogService/CreatePost"} 1`,
+		`blog_grpc_requests_total{code="NotFound",method="/posts.BlogService/GetPost"} 1`,
+		`blog_grpc_request_duration_seconds_count{method="/posts.BlogService/CreatePost"} 1`,
+		`blog_grpc_requests_in_flight{method="/posts.BlogService/CreatePost"} 0`,
+		`blog_posts{blog="default"} 1`,
+		`blog_dao_operation_duration_seconds_count{operation="create",result="ok"}`,
+		`blog_dao_lock_wait_seconds_count{mode="write"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected the metrics to contain %s", want)
+		}
+	}
+}
+
+func TestTracingIntegration(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	provider, shutdownTracing, err := newTracerProvider(context.Background(), config.TracingConfig{Exporter: "file", File: file, SampleRatio: 0})
+	if err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	initPostsService(dao.NewMemoryBlogRepository())
+	s, err := createServer(config.Default())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.registerService(s.server)
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.serve(listen)
+	t.Cleanup(s.server.Stop)
+	client := setupClient(listen.Addr().String())
+
+	// The caller sampled the trace, so it is traced despite the sample ratio of 0.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := client.CreatePost(ctx, &posts.CreatePostRequest{
+		Title:           "Test Post",
+		Content:         "Test Content",
+		Author:          "alice",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	}); err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	if _, err := client.GetPost(context.Background(), &posts.GetPostRequest{PostId: 1}); err != nil {
+		t.Fatalf("failed to get post: %v", err)
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		t.Fatalf("failed to flush traces: %v", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read traces: %v", err)
+	}
+	traced := make(map[string]string)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+		}
+		if err := decoder.Decode(&span); err != nil {
+			t.Fatalf("failed to decode span: %v", err)
+		}
+		traced[span.Name] = span.SpanContext.TraceID
+	}
+	for _, name := range []string{"posts.BlogService/CreatePost", "PostsService.CreatePost", "dao.create", "RateLimit", "ConcurrencyLimit"} {
+		if traceId, ok := traced[name]; !ok || traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected a span %s of the caller's trace, got spans %v", name, traced)
+		}
+	}
+	if len(traced) != 5 {
+		t.Errorf("expected only the spans of the sampled call, got %v", traced)
+	}
+}
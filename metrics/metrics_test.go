@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerMetricsUnaryInterceptor(t *testing.T) {
+	m := NewServerMetrics()
+	info := &grpc.UnaryServerInfo{FullMethod: "/posts.BlogService/GetPost"}
+	_, _ = m.UnaryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if inFlight := testutil.ToFloat64(m.inFlight.WithLabelValues(info.FullMethod)); inFlight != 1 {
+			t.Errorf("expected 1 call in flight while handled, got %v", inFlight)
+		}
+		return nil, nil
+	})
+	for i := 0; i < 2; i++ {
+		_, _ = m.UnaryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "not found")
+		})
+	}
+
+	expected := `
+# HELP blog_grpc_requests_total gRPC calls handled, by method and status code.
+# TYPE blog_grpc_requests_total counter
+blog_grpc_requests_total{code="NotFound",method="/posts.BlogService/GetPost"} 2
+blog_grpc_requests_total{code="OK",method="/posts.BlogService/GetPost"} 1
+# HELP blog_grpc_requests_in_flight gRPC calls being handled, by method.
+# TYPE blog_grpc_requests_in_flight gauge
+blog_grpc_requests_in_flight{method="/posts.BlogService/GetPost"} 0
+`
+	if err := testutil.CollectAndCompare(m, strings.NewReader(expected), "blog_grpc_requests_total", "blog_grpc_requests_in_flight"); err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(m, "blog_grpc_request_duration_seconds"); count != 1 {
+		t.Fatalf("expected a latency histogram of the method, got %d", count)
+	}
+}
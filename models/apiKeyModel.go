@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ApiKey is a key callers authenticate with instead of a bearer token. Only a
+// salted hash of its secret is stored.
+type ApiKey struct {
+	KeyId string `json:"key_id"`
+	Name  string `json:"name"`
+	// Owner is the subject of the principal that created the key, and Roles
+	// the roles calls made with the key have.
+	Owner string   `json:"owner"`
+	Roles []string `json:"roles"`
+	Scope string   `json:"scope"`
+	Salt  []byte   `json:"salt"`
+	Hash  []byte   `json:"hash"`
+	// ExpiresAt, LastUsedAt and RevokedAt are zero until they apply.
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	RevokedAt  time.Time `json:"revoked_at"`
+}
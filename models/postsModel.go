@@ -1,10 +1,26 @@
 package models
 
+import "time"
+
 type Post struct {
-	PostId          uint64   `json:"post_id"`
-	Title           string   `json:"title"`
-	Content         string   `json:"content"`
-	Author          string   `json:"author"`
-	PublicationDate string   `json:"publication_date"`
-	Tags            []string `json:"tags"`
+	PostId          uint64    `json:"post_id"`
+	Title           string    `json:"title"`
+	Content         string    `json:"content"`
+	Author          string    `json:"author"`
+	PublicationDate string    `json:"publication_date"`
+	Tags            []string  `json:"tags"`
+	Version         uint64    `json:"version"`
+	UpdatedBy       string    `json:"updated_by"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	// DeletedAt is set while the post is in the trash.
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by"`
+}
+
+// PostRevision is an immutable snapshot of a post, taken every time it is
+// written. Post.Version numbers the revision.
+type PostRevision struct {
+	Post Post `json:"post"`
+	// ChangedFields names the fields that differ from the previous revision.
+	ChangedFields []string `json:"changed_fields"`
 }
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"cloudbees/auth"
+	e "cloudbees/errors"
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// retryAfterMetadataKey is the response metadata telling rejected callers
+// how many seconds to wait before they retry.
+const retryAfterMetadataKey = "retry-after"
+
+// shedRetryAfter is how long callers shed for lack of capacity should wait.
+const shedRetryAfter = time.Second
+
+// exhausted returns the codes.ResourceExhausted error of a rejected call,
+// setting its retry-after metadata and RetryInfo detail.
+func exhausted(ctx context.Context, err error, retryAfter time.Duration) error {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadataKey, strconv.FormatInt(seconds, 10)))
+	st := status.New(codes.ResourceExhausted, err.Error())
+	if detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(seconds) * time.Second)}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// clientKey identifies who makes the call: the API key or the principal it
+// authenticated as, or else its address.
+func clientKey(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		if principal.ApiKeyId != "" {
+			return "key:" + principal.ApiKeyId
+		}
+		return "principal:" + principal.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "peer:" + host
+	}
+	return "unknown"
+}
+
+// RateLimit admits calls as fast as the budget of their client allows, with
+// separate budgets for reads and writes. It runs after authentication, so
+// that clients are told apart by who they are rather than where they are.
+type RateLimit struct {
+	reads  *Limiter
+	writes *Limiter
+	isRead func(method string) bool
+}
+
+// NewRateLimit returns a RateLimit limiting the calls of the methods isRead
+// reports true for with reads, and the others with writes. Nil limiters
+// admit every call.
+func NewRateLimit(reads *Limiter, writes *Limiter, isRead func(method string) bool) *RateLimit {
+	return &RateLimit{reads: reads, writes: writes, isRead: isRead}
+}
+
+// UnaryInterceptor rate limits unary calls.
+func (r *RateLimit) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	limiter := r.writes
+	if r.isRead(info.FullMethod) {
+		limiter = r.reads
+	}
+	if limiter != nil {
+		if ok, retryAfter := limiter.Allow(clientKey(ctx)); !ok {
+			return nil, exhausted(ctx, e.RateLimitedError, retryAfter)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// ConcurrencyLimit sheds the calls that arrive while the server is handling
+// as many as it may at once. Only tracing and metrics run before it, so shed
+// calls cost next to nothing.
+type ConcurrencyLimit struct {
+	max      int64
+	inFlight atomic.Int64
+}
+
+// NewConcurrencyLimit returns a ConcurrencyLimit handling at most max calls
+// at once.
+func NewConcurrencyLimit(max int) *ConcurrencyLimit {
+	return &ConcurrencyLimit{max: int64(max)}
+}
+
+// UnaryInterceptor limits the unary calls in flight.
+func (c *ConcurrencyLimit) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if c.inFlight.Add(1) > c.max {
+		c.inFlight.Add(-1)
+		return nil, exhausted(ctx, e.ServerOverloadedError, shedRetryAfter)
+	}
+	defer c.inFlight.Add(-1)
+	return handler(ctx, req)
+}
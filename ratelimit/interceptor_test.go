@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"cloudbees/auth"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func ok(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestClientKey(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4242}
+	fromPeer := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		key  string
+	}{
+		{"API key", auth.NewContext(fromPeer, &auth.Principal{Subject: "alice", ApiKeyId: "0123"}), "key:0123"},
+		{"principal", auth.NewContext(fromPeer, &auth.Principal{Subject: "alice"}), "principal:alice"},
+		{"peer", fromPeer, "peer:192.0.2.1"},
+		{"unknown", context.Background(), "unknown"},
+	}
+	for _, test := range tests {
+		if key := clientKey(test.ctx); key != test.key {
+			t.Errorf("%s: expected key %q, got %q", test.name, test.key, key)
+		}
+	}
+}
+
+func TestRateLimitSeparatesReadsAndWrites(t *testing.T) {
+	reads, writes := NewLimiter(1, 1), NewLimiter(1, 1)
+	r := NewRateLimit(reads, writes, func(method string) bool { return method == "/Get" })
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "alice"})
+	call := func(method string) error {
+		_, err := r.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, ok)
+		return err
+	}
+
+	if err := call("/Get"); err != nil {
+		t.Fatalf("expected the first read to be allowed, got %v", err)
+	}
+	if err := call("/Put"); err != nil {
+		t.Fatalf("expected the first write to be allowed despite the read, got %v", err)
+	}
+	err := call("/Get")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected %v for the second read, got %v", codes.ResourceExhausted, err)
+	}
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if retryInfo == nil || retryInfo.RetryDelay.AsDuration() != time.Second {
+		t.Fatalf("expected a retry delay of 1s, got %v", retryInfo)
+	}
+
+	unlimited := NewRateLimit(nil, nil, func(string) bool { return true })
+	for i := 0; i < 10; i++ {
+		if _, err := unlimited.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Get"}, ok); err != nil {
+			t.Fatalf("expected calls without limiters to be allowed, got %v", err)
+		}
+	}
+}
+
+func TestConcurrencyLimitShedsExcessCalls(t *testing.T) {
+	c := NewConcurrencyLimit(1)
+	info := &grpc.UnaryServerInfo{FullMethod: "/Get"}
+	entered, release, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		_, err := c.UnaryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(entered)
+			<-release
+			return nil, nil
+		})
+		done <- err
+	}()
+	<-entered
+
+	if _, err := c.UnaryInterceptor(context.Background(), nil, info, ok); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected %v while at capacity, got %v", codes.ResourceExhausted, err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the call in flight to succeed, got %v", err)
+	}
+	if _, err := c.UnaryInterceptor(context.Background(), nil, info, ok); err != nil {
+		t.Fatalf("expected a call to be allowed once capacity freed, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimiter returns a Limiter whose clock only moves with the returned
+// advance function.
+func testLimiter(rate float64, burst int) (*Limiter, func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(rate, burst)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterAllowsBurstsThenRate(t *testing.T) {
+	l, advance := testLimiter(2, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("alice"); !ok {
+			t.Fatalf("expected call %d of the burst to be allowed", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("alice")
+	if ok {
+		t.Fatalf("expected the call after the burst to be refused")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Fatalf("expected to retry after 500ms, got %v", retryAfter)
+	}
+	if ok, _ := l.Allow("bob"); !ok {
+		t.Fatalf("expected another key to have its own bucket")
+	}
+
+	advance(retryAfter)
+	if ok, _ := l.Allow("alice"); !ok {
+		t.Fatalf("expected a call to be allowed once a token refilled")
+	}
+	if ok, _ := l.Allow("alice"); ok {
+		t.Fatalf("expected the next call to be refused")
+	}
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("alice"); !ok {
+			t.Fatalf("expected the bucket to refill up to the burst, call %d refused", i+1)
+		}
+	}
+	if ok, _ := l.Allow("alice"); ok {
+		t.Fatalf("expected the bucket to hold no more than the burst")
+	}
+}
+
+func TestLimiterSweepsRefilledBuckets(t *testing.T) {
+	l, advance := testLimiter(1, 10)
+	l.Allow("alice")
+	advance(sweepInterval)
+	for i := 0; i < 5; i++ {
+		l.Allow("bob")
+	}
+	if size := l.size(); size != 1 {
+		t.Fatalf("expected only bob's bucket to be kept, got %d buckets", size)
+	}
+	advance(sweepInterval)
+	l.Allow("carol")
+	if size := l.size(); size != 1 {
+		t.Fatalf("expected only carol's bucket to be kept, got %d buckets", size)
+	}
+}
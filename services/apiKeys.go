@@ -0,0 +1,222 @@
+package services
+
+import (
+	"cloudbees/auth"
+	d "cloudbees/dao"
+	e "cloudbees/errors"
+	"cloudbees/genproto/apikeys"
+	m "cloudbees/models"
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// lastUsedResolution is how stale the recorded last use of an API key may
+// get, so that busy keys aren't written on every call.
+const lastUsedResolution = time.Minute
+
+// maxApiKeyRole is the most a key may do: what only admins may, like managing
+// blogs, takes a bearer token.
+const maxApiKeyRole = auth.RoleEditor
+
+// Scopes as stored with the keys.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+type ApiKeyService struct {
+	apikeys.UnimplementedApiKeyServiceServer
+	keys d.ApiKeyRepository
+}
+
+func NewApiKeyService(keys d.ApiKeyRepository) *ApiKeyService {
+	return &ApiKeyService{keys: keys}
+}
+
+// manager returns the principal managing keys, which must have authenticated
+// with a bearer token: keys can't be used to create more keys.
+func manager(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, e.AuthenticationMissingError.Error())
+	}
+	if principal.ApiKeyId != "" {
+		return nil, status.Error(codes.PermissionDenied, e.ApiKeyManagementError.Error())
+	}
+	return principal, nil
+}
+
+// keyRoles caps roles at maxApiKeyRole.
+func keyRoles(roles []auth.Role) []auth.Role {
+	capped := make([]auth.Role, 0, len(roles))
+	for _, role := range roles {
+		if role == auth.RoleAdmin {
+			role = maxApiKeyRole
+		}
+		capped = append(capped, role)
+	}
+	return capped
+}
+
+// manages reports whether the principal may see and revoke the key.
+func manages(principal *auth.Principal, key *m.ApiKey) bool {
+	return key.Owner == principal.Subject || principal.HasRole(auth.RoleAdmin)
+}
+
+func convertToApiKey(key *m.ApiKey) *apikeys.ApiKey {
+	scope := apikeys.Scope_SCOPE_READ
+	if key.Scope == scopeWrite {
+		scope = apikeys.Scope_SCOPE_WRITE
+	}
+	return &apikeys.ApiKey{
+		KeyId:        key.KeyId,
+		Name:         key.Name,
+		Owner:        key.Owner,
+		Scope:        scope,
+		CreateTime:   timestamp(key.CreatedAt),
+		ExpireTime:   timestamp(key.ExpiresAt),
+		LastUsedTime: timestamp(key.LastUsedAt),
+		RevokeTime:   timestamp(key.RevokedAt),
+	}
+}
+
+func (s *ApiKeyService) CreateApiKey(ctx context.Context, in *apikeys.CreateApiKeyRequest) (*apikeys.CreateApiKeyResponse, error) {
+	principal, err := manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, e.ApiKeyNameMissingError.Error())
+	}
+	now := time.Now().UTC()
+	var expiresAt time.Time
+	if in.ExpireTime != nil {
+		expiresAt = in.ExpireTime.AsTime()
+		if !expiresAt.After(now) {
+			return nil, status.Error(codes.InvalidArgument, e.InvalidApiKeyExpiryError.Error())
+		}
+	}
+
+	// Write keys act with the roles of their owner up to maxApiKeyRole, read
+	// keys as readers.
+	var scope string
+	var roles []string
+	switch in.Scope {
+	case apikeys.Scope_SCOPE_READ:
+		scope, roles = scopeRead, []string{string(auth.RoleReader)}
+	case apikeys.Scope_SCOPE_WRITE:
+		if !principal.HasRole(auth.RoleAuthor) {
+			return nil, status.Error(codes.PermissionDenied, e.PermissionDeniedError.Error())
+		}
+		scope = scopeWrite
+		for _, role := range keyRoles(principal.Roles) {
+			roles = append(roles, string(role))
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, e.InvalidApiKeyScopeError.Error())
+	}
+
+	secretKey, id, secret, err := auth.GenerateApiKey()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	salt, err := auth.NewSalt()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	key := &m.ApiKey{
+		KeyId:     id,
+		Name:      in.Name,
+		Owner:     principal.Subject,
+		Roles:     roles,
+		Scope:     scope,
+		Salt:      salt,
+		Hash:      auth.HashApiKeySecret(salt, secret),
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.keys.Create(key); err != nil {
+		return nil, daoStatusError(err)
+	}
+	return &apikeys.CreateApiKeyResponse{
+		ApiKey: convertToApiKey(key),
+		Key:    secretKey,
+	}, nil
+}
+
+// ListApiKeys lists the caller's keys, or every key for admins.
+func (s *ApiKeyService) ListApiKeys(ctx context.Context, in *apikeys.ListApiKeysRequest) (*apikeys.ListApiKeysResponse, error) {
+	principal, err := manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.keys.List()
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	response := &apikeys.ListApiKeysResponse{}
+	for _, key := range keys {
+		if manages(principal, key) {
+			response.ApiKeys = append(response.ApiKeys, convertToApiKey(key))
+		}
+	}
+	return response, nil
+}
+
+// RevokeApiKey revokes one of the caller's keys, or any key for admins.
+// Revoking a revoked key changes nothing.
+func (s *ApiKeyService) RevokeApiKey(ctx context.Context, in *apikeys.RevokeApiKeyRequest) (*apikeys.ApiKey, error) {
+	principal, err := manager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.keys.Read(in.KeyId)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	// Keys of others are not found, rather than forbidden, to keep their ids secret.
+	if !manages(principal, key) {
+		return nil, daoStatusError(e.EnitityNotFoundError)
+	}
+	// Revoke only sets the revocation, so it can't undo a concurrent Touch.
+	key, err = s.keys.Revoke(in.KeyId, time.Now().UTC())
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	return convertToApiKey(key), nil
+}
+
+// VerifyApiKey returns the principal of the key, recording that it was used.
+func (s *ApiKeyService) VerifyApiKey(ctx context.Context, secretKey string) (*auth.Principal, error) {
+	id, secret, ok := auth.ParseApiKey(secretKey)
+	if !ok {
+		return nil, e.InvalidApiKeyError
+	}
+	key, err := s.keys.Read(id)
+	if errors.Is(err, e.EnitityNotFoundError) {
+		return nil, e.InvalidApiKeyError
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if !auth.ApiKeySecretMatches(key.Salt, key.Hash, secret) ||
+		!key.RevokedAt.IsZero() ||
+		(!key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt)) {
+		return nil, e.InvalidApiKeyError
+	}
+	if now.Sub(key.LastUsedAt) >= lastUsedResolution {
+		// Failing to record the use doesn't fail the call.
+		_ = s.keys.Touch(key.KeyId, now)
+	}
+	// Capping again covers keys stored before their roles were capped.
+	return &auth.Principal{
+		Subject:  key.Owner,
+		Roles:    keyRoles(auth.ParseRoles(key.Roles)),
+		ApiKeyId: key.KeyId,
+	}, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"cloudbees/auth"
+	d "cloudbees/dao"
+	"cloudbees/genproto/apikeys"
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func withPrincipal(subject string, roles ...auth.Role) context.Context {
+	return auth.NewContext(context.Background(), &auth.Principal{Subject: subject, Roles: roles})
+}
+
+func TestApiKeyLifecycle(t *testing.T) {
+	service := NewApiKeyService(d.NewApiKeyDAO())
+	alice := withPrincipal("alice", auth.RoleAuthor)
+
+	created, err := service.CreateApiKey(alice, &apikeys.CreateApiKeyRequest{Name: "nightly import", Scope: apikeys.Scope_SCOPE_WRITE})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	principal, err := service.VerifyApiKey(context.Background(), created.Key)
+	if err != nil {
+		t.Fatalf("expected the key to verify, got %v", err)
+	}
+	if principal.Subject != "alice" || !principal.HasRole(auth.RoleAuthor) || principal.ApiKeyId != created.ApiKey.KeyId {
+		t.Fatalf("expected the key to act as author alice, got %+v", principal)
+	}
+	if _, err := service.VerifyApiKey(context.Background(), created.Key+"x"); err == nil {
+		t.Fatalf("expected a wrong secret to be rejected")
+	}
+
+	list, err := service.ListApiKeys(alice, &apikeys.ListApiKeysRequest{})
+	if err != nil || len(list.ApiKeys) != 1 || list.ApiKeys[0].LastUsedTime == nil {
+		t.Fatalf("expected alice's key with its last use, got %v, %v", list, err)
+	}
+	list, err = service.ListApiKeys(withPrincipal("bob", auth.RoleAuthor), &apikeys.ListApiKeysRequest{})
+	if err != nil || len(list.ApiKeys) != 0 {
+		t.Fatalf("expected bob to see none of alice's keys, got %v, %v", list, err)
+	}
+
+	viaKey := auth.NewContext(context.Background(), principal)
+	if _, err := service.CreateApiKey(viaKey, &apikeys.CreateApiKeyRequest{Name: "more", Scope: apikeys.Scope_SCOPE_READ}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected keys to be refused managing keys with %v, got %v", codes.PermissionDenied, err)
+	}
+	if _, err := service.RevokeApiKey(withPrincipal("bob", auth.RoleEditor), &apikeys.RevokeApiKeyRequest{KeyId: created.ApiKey.KeyId}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected bob's revoke of alice's key to fail with %v, got %v", codes.NotFound, err)
+	}
+	revoked, err := service.RevokeApiKey(alice, &apikeys.RevokeApiKeyRequest{KeyId: created.ApiKey.KeyId})
+	if err != nil || revoked.RevokeTime == nil {
+		t.Fatalf("expected alice to revoke her key, got %v, %v", revoked, err)
+	}
+	if _, err := service.VerifyApiKey(context.Background(), created.Key); err == nil {
+		t.Fatalf("expected a revoked key to be rejected")
+	}
+}
+
+func TestWriteApiKeysOfAdminsActAsEditors(t *testing.T) {
+	service := NewApiKeyService(d.NewApiKeyDAO())
+	created, err := service.CreateApiKey(withPrincipal("dave", auth.RoleAdmin), &apikeys.CreateApiKeyRequest{Name: "deploy", Scope: apikeys.Scope_SCOPE_WRITE})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	principal, err := service.VerifyApiKey(context.Background(), created.Key)
+	if err != nil || !principal.HasRole(auth.RoleEditor) || principal.HasRole(auth.RoleAdmin) {
+		t.Fatalf("expected an admin's write key to act as an editor, got %+v, %v", principal, err)
+	}
+}
+
+func TestCreateApiKeyValidation(t *testing.T) {
+	service := NewApiKeyService(d.NewApiKeyDAO())
+	reader := withPrincipal("carol", auth.RoleReader)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		request *apikeys.CreateApiKeyRequest
+		code    codes.Code
+	}{
+		{"anonymous", context.Background(), &apikeys.CreateApiKeyRequest{Name: "key", Scope: apikeys.Scope_SCOPE_READ}, codes.Unauthenticated},
+		{"without name", reader, &apikeys.CreateApiKeyRequest{Scope: apikeys.Scope_SCOPE_READ}, codes.InvalidArgument},
+		{"without scope", reader, &apikeys.CreateApiKeyRequest{Name: "key"}, codes.InvalidArgument},
+		{"expired", reader, &apikeys.CreateApiKeyRequest{Name: "key", Scope: apikeys.Scope_SCOPE_READ, ExpireTime: timestamppb.New(time.Now().Add(-time.Minute))}, codes.InvalidArgument},
+		{"write scope for a reader", reader, &apikeys.CreateApiKeyRequest{Name: "key", Scope: apikeys.Scope_SCOPE_WRITE}, codes.PermissionDenied},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := service.CreateApiKey(test.ctx, test.request); status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+		})
+	}
+
+	created, err := service.CreateApiKey(reader, &apikeys.CreateApiKeyRequest{Name: "key", Scope: apikeys.Scope_SCOPE_READ, ExpireTime: timestamppb.New(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	principal, err := service.VerifyApiKey(context.Background(), created.Key)
+	if err != nil || principal.HasRole(auth.RoleAuthor) {
+		t.Fatalf("expected a read key to act as a reader, got %+v, %v", principal, err)
+	}
+}
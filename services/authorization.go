@@ -0,0 +1,110 @@
+package services
+
+import (
+	"cloudbees/auth"
+	d "cloudbees/dao"
+	e "cloudbees/errors"
+	m "cloudbees/models"
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RequiredRoles maps the full names of the BlogService and ApiKeyService
+// methods to the role callers need for them. Authors are further limited to
+// their own posts, and everyone to their own API keys, by the methods
+// themselves.
+var RequiredRoles = map[string]auth.Role{
+	"/posts.BlogService/GetPost":           auth.RoleReader,
+	"/posts.BlogService/ListPosts":         auth.RoleReader,
+	"/posts.BlogService/SearchPosts":       auth.RoleReader,
+	"/posts.BlogService/ListPostRevisions": auth.RoleReader,
+	"/posts.BlogService/GetPostRevision":   auth.RoleReader,
+	"/posts.BlogService/DiffPostRevisions": auth.RoleReader,
+	"/posts.BlogService/ListBlogs":         auth.RoleReader,
+	"/posts.BlogService/CreatePost":        auth.RoleAuthor,
+	"/posts.BlogService/UpdatePost":        auth.RoleAuthor,
+	"/posts.BlogService/UpsertPost":        auth.RoleAuthor,
+	"/posts.BlogService/DeletePost":        auth.RoleAuthor,
+	"/posts.BlogService/RevertPost":        auth.RoleAuthor,
+	"/posts.BlogService/ListDeletedPosts":  auth.RoleAuthor,
+	"/posts.BlogService/RestorePost":       auth.RoleAuthor,
+	"/posts.BlogService/PurgePost":         auth.RoleEditor,
+	"/posts.BlogService/CreateBlog":        auth.RoleAdmin,
+	"/posts.BlogService/DeleteBlog":        auth.RoleAdmin,
+	"/apikeys.ApiKeyService/CreateApiKey":  auth.RoleReader,
+	"/apikeys.ApiKeyService/ListApiKeys":   auth.RoleReader,
+	"/apikeys.ApiKeyService/RevokeApiKey":  auth.RoleReader,
+}
+
+// ReadMethods holds the full names of the methods that only read, which are
+// rate limited apart from the others.
+var ReadMethods = map[string]bool{
+	"/posts.BlogService/GetPost":           true,
+	"/posts.BlogService/ListPosts":         true,
+	"/posts.BlogService/SearchPosts":       true,
+	"/posts.BlogService/ListPostRevisions": true,
+	"/posts.BlogService/GetPostRevision":   true,
+	"/posts.BlogService/DiffPostRevisions": true,
+	"/posts.BlogService/ListBlogs":         true,
+	"/posts.BlogService/ListDeletedPosts":  true,
+	"/apikeys.ApiKeyService/ListApiKeys":   true,
+}
+
+// IsReadMethod reports whether the method of the full name only reads.
+func IsReadMethod(fullMethod string) bool {
+	return ReadMethods[fullMethod]
+}
+
+// postAuthor returns the author of a post the caller writes, given the one
+// the request names. Authors write as themselves, editors as anyone. Without
+// authentication the requested author is trusted.
+func postAuthor(ctx context.Context, requested string) (string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return requested, nil
+	}
+	if requested == "" || requested == principal.Subject {
+		return principal.Subject, nil
+	}
+	if err := authorizeAuthor(ctx, requested); err != nil {
+		return "", err
+	}
+	return requested, nil
+}
+
+// authorizeAuthor fails with codes.PermissionDenied unless the caller may
+// write posts of the author: their own, or any for editors. Without
+// authentication every caller may.
+func authorizeAuthor(ctx context.Context, author string) error {
+	if writesAuthor(ctx, author) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, e.NotPostAuthorError.Error())
+}
+
+// writesAuthor reports whether the caller may write posts of the author, as
+// authorizeAuthor checks.
+func writesAuthor(ctx context.Context, author string) bool {
+	principal, ok := auth.FromContext(ctx)
+	return !ok || principal.HasRole(auth.RoleEditor) || principal.Subject == author
+}
+
+// readDeleted returns the post with the id from the trash.
+func readDeleted(blog d.PostRepository, id uint64) (*m.Post, error) {
+	var found *m.Post
+	err := blog.ScanDeleted(id-1, func(post *m.Post) bool {
+		if post.PostId == id {
+			found = post
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, e.EnitityNotFoundError
+	}
+	return found, nil
+}
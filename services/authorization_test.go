@@ -0,0 +1,28 @@
+package services
+
+import (
+	"cloudbees/genproto/apikeys"
+	"cloudbees/genproto/posts"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestRequiredRolesCoverEveryMethod(t *testing.T) {
+	for _, service := range []grpc.ServiceDesc{posts.BlogService_ServiceDesc, apikeys.ApiKeyService_ServiceDesc} {
+		for _, method := range service.Methods {
+			name := "/" + service.ServiceName + "/" + method.MethodName
+			if _, ok := RequiredRoles[name]; !ok {
+				t.Errorf("%s has no required role, only admins may call it", name)
+			}
+		}
+	}
+}
+
+func TestReadMethodsExist(t *testing.T) {
+	for name := range ReadMethods {
+		if _, ok := RequiredRoles[name]; !ok {
+			t.Errorf("read method %s is not a method of the services", name)
+		}
+	}
+}
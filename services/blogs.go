@@ -0,0 +1,36 @@
+package services
+
+import (
+	"cloudbees/genproto/posts"
+	"context"
+)
+
+func (s *PostsService) CreateBlog(ctx context.Context, in *posts.CreateBlogRequest) (*posts.Blog, error) {
+	_, span := startSpan(ctx, "CreateBlog")
+	defer span.End()
+	if err := s.blogs.CreateBlog(in.BlogId); err != nil {
+		return nil, daoStatusError(err)
+	}
+	return &posts.Blog{BlogId: in.BlogId}, nil
+}
+
+func (s *PostsService) DeleteBlog(ctx context.Context, in *posts.DeleteBlogRequest) (*posts.DeleteBlogResponse, error) {
+	_, span := startSpan(ctx, "DeleteBlog")
+	defer span.End()
+	if err := s.blogs.DeleteBlog(in.BlogId); err != nil {
+		return nil, daoStatusError(err)
+	}
+	return &posts.DeleteBlogResponse{
+		Message: "Blog deleted successfully",
+	}, nil
+}
+
+func (s *PostsService) ListBlogs(ctx context.Context, in *posts.ListBlogsRequest) (*posts.ListBlogsResponse, error) {
+	_, span := startSpan(ctx, "ListBlogs")
+	defer span.End()
+	response := &posts.ListBlogsResponse{}
+	for _, id := range s.blogs.Blogs() {
+		response.Blogs = append(response.Blogs, &posts.Blog{BlogId: id})
+	}
+	return response, nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around every change.
+const diffContext = 3
+
+// diffSearchLimit bounds the edit steps searched for a shortest edit script of
+// a range before it is diffed as replaced whole, keeping the time spent on
+// texts with little in common in check.
+const diffSearchLimit = 1000
+
+type diffLine struct {
+	// kind is ' ' for a line in both texts, '-' for a line only in the old
+	// text and '+' for a line only in the new one.
+	kind byte
+	text string
+	// fromLine and toLine are the 1-based line numbers in each text of the
+	// lines up to and including this one.
+	fromLine, toLine int
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines aligns the lines of from and to along a shortest edit script,
+// found with Myers' linear space algorithm: revisions are readable by every
+// reader, so the diff must not allocate in proportion to both texts' lengths.
+func diffLines(from []string, to []string) []diffLine {
+	d := differ{from: from, to: to, common: make([]bool, 0, len(from)+len(to))}
+	d.compare(0, len(from), 0, len(to))
+
+	// Every run of changes lists the removed lines before the added ones.
+	lines := make([]diffLine, 0, len(from)+len(to))
+	i, j := 0, 0
+	for at := 0; at < len(d.common); {
+		if d.common[at] {
+			i, j = i+1, j+1
+			lines = append(lines, diffLine{kind: ' ', text: from[i-1], fromLine: i, toLine: j})
+			at++
+			continue
+		}
+		removed, added := 0, 0
+		for ; at < len(d.common) && !d.common[at]; at++ {
+			if d.removes[at] {
+				removed++
+			} else {
+				added++
+			}
+		}
+		for ; removed > 0; removed-- {
+			i++
+			lines = append(lines, diffLine{kind: '-', text: from[i-1], fromLine: i, toLine: j})
+		}
+		for ; added > 0; added-- {
+			j++
+			lines = append(lines, diffLine{kind: '+', text: to[j-1], fromLine: i, toLine: j})
+		}
+	}
+	return lines
+}
+
+// differ records the edit script turning from into to, one step per line:
+// common steps keep a line of both texts, the others remove a line of from
+// when removes is set and add a line of to otherwise.
+type differ struct {
+	from, to []string
+	common   []bool
+	removes  []bool
+}
+
+func (d *differ) step(common bool, removes bool, count int) {
+	for ; count > 0; count-- {
+		d.common = append(d.common, common)
+		d.removes = append(d.removes, removes)
+	}
+}
+
+// compare records the edit script turning from[fromLo:fromHi] into
+// to[toLo:toHi].
+func (d *differ) compare(fromLo, fromHi, toLo, toHi int) {
+	prefix := 0
+	for fromLo+prefix < fromHi && toLo+prefix < toHi && d.from[fromLo+prefix] == d.to[toLo+prefix] {
+		prefix++
+	}
+	d.step(true, false, prefix)
+	fromLo, toLo = fromLo+prefix, toLo+prefix
+	suffix := 0
+	for fromLo < fromHi-suffix && toLo < toHi-suffix && d.from[fromHi-suffix-1] == d.to[toHi-suffix-1] {
+		suffix++
+	}
+	fromHi, toHi = fromHi-suffix, toHi-suffix
+
+	switch {
+	case fromLo == fromHi || toLo == toHi:
+		d.step(false, true, fromHi-fromLo)
+		d.step(false, false, toHi-toLo)
+	default:
+		if x, y, ok := d.middle(fromLo, fromHi, toLo, toHi); ok {
+			d.compare(fromLo, x, toLo, y)
+			d.compare(x, fromHi, y, toHi)
+		} else {
+			d.step(false, true, fromHi-fromLo)
+			d.step(false, false, toHi-toLo)
+		}
+	}
+	d.step(true, false, suffix)
+}
+
+// middle returns where the forward and backward searches for a shortest edit
+// script of from[fromLo:fromHi] into to[toLo:toHi] meet, splitting it in two,
+// or false when the texts have no line in common or the search gives up. Both ranges are non-empty
+// and differ in their first and last lines.
+func (d *differ) middle(fromLo, fromHi, toLo, toHi int) (int, int, bool) {
+	n, m := fromHi-fromLo, toHi-toLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] is the furthest x reached on diagonal k = x - y from
+	// the start, backward[offset+k] the furthest reached from the end.
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for k := range forward {
+		forward[k], backward[k] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// With an odd delta the forward search is the one to detect the overlap.
+	front := delta%2 != 0
+	// Diagonals that ran off the edges of the grid are skipped.
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+	for step := 0; step < maxD && step < diffSearchLimit; step++ {
+		for k := -step + forwardStart; k <= step-forwardEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.from[fromLo+x] == d.to[toLo+y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case front:
+				if back := offset + delta - k; back >= 0 && back < len(backward) && backward[back] != -1 && x >= n-backward[back] {
+					return fromLo + x, toLo + y, true
+				}
+			}
+		}
+		for k := -step + backwardStart; k <= step-backwardEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.from[fromHi-x-1] == d.to[toHi-y-1] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !front:
+				if fwd := offset + delta - k; fwd >= 0 && fwd < len(forward) && forward[fwd] != -1 && forward[fwd] >= n-x {
+					forwardX := forward[fwd]
+					return fromLo + forwardX, toLo + forwardX - (fwd - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// unifiedDiff returns the unified diff turning from into to, or an empty
+// string when they have the same lines.
+func unifiedDiff(fromName string, toName string, from string, to string) string {
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	var diff strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change and extend the hunk while changes are close
+		// enough for their context to touch.
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for next := first; next < len(lines); next++ {
+			if lines[next].kind == ' ' {
+				continue
+			}
+			if next-last-1 > 2*diffContext {
+				break
+			}
+			last = next
+		}
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		if diff.Len() == 0 {
+			fmt.Fprintf(&diff, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		writeHunk(&diff, lines, from, to)
+		start = to
+	}
+	return diff.String()
+}
+
+func writeHunk(diff *strings.Builder, lines []diffLine, from int, to int) {
+	fromStart, toStart := 1, 1
+	if from > 0 {
+		fromStart, toStart = lines[from-1].fromLine+1, lines[from-1].toLine+1
+	}
+	fromCount := lines[to-1].fromLine - fromStart + 1
+	toCount := lines[to-1].toLine - toStart + 1
+	// An empty range is addressed by the line before it.
+	if fromCount == 0 {
+		fromStart--
+	}
+	if toCount == 0 {
+		toStart--
+	}
+	fmt.Fprintf(diff, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+	for _, line := range lines[from:to] {
+		diff.WriteByte(line.kind)
+		diff.WriteString(line.text)
+		diff.WriteByte('\n')
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	to := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+	expected := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if diff := unifiedDiff("a", "b", from, to); diff != expected {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if diff := unifiedDiff("a", "b", from, from); diff != "" {
+		t.Fatalf("expected no diff for equal texts, got:\n%s", diff)
+	}
+	if diff := unifiedDiff("a", "b", "", "new\n"); diff != "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n" {
+		t.Fatalf("unexpected diff from empty text:\n%s", diff)
+	}
+}
+
+func TestDiffLinesOfLongTexts(t *testing.T) {
+	from := make([]string, 50000)
+	to := make([]string, 50000)
+	for i := range from {
+		from[i], to[i] = fmt.Sprint("from ", i), fmt.Sprint("to ", i)
+	}
+	to[25000] = from[25000]
+	lines := diffLines(from, to)
+	if len(lines) > len(from)+len(to) {
+		t.Fatalf("expected at most %d lines, got %d", len(from)+len(to), len(lines))
+	}
+	if last := lines[len(lines)-1]; last.fromLine != len(from) || last.toLine != len(to) {
+		t.Fatalf("expected the diff to cover both texts, ended at %d,%d", last.fromLine, last.toLine)
+	}
+}
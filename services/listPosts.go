@@ -0,0 +1,315 @@
+package services
+
+import (
+	d "cloudbees/dao"
+	e "cloudbees/errors"
+	"cloudbees/genproto/posts"
+	m "cloudbees/models"
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+
+	// dateLayout is the Go layout of the dd-mm-yyyy publication date format.
+	dateLayout = "02-01-2006"
+	// sortDateLayout formats publication dates in page tokens so they sort
+	// lexically in date order.
+	sortDateLayout = "20060102"
+
+	orderByPostId          = "post_id"
+	orderByTitle           = "title"
+	orderByAuthor          = "author"
+	orderByPublicationDate = "publication_date"
+)
+
+// pageToken is the decoded form of the opaque page token handed to clients.
+// It remembers the sort key and id of the last post on the previous page, and
+// a fingerprint of the request so a token can't be replayed with other filters.
+type pageToken struct {
+	Request string `json:"r"`
+	Key     string `json:"k"`
+	PostId  uint64 `json:"i"`
+}
+
+func encodePageToken(token interface{}) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken decodes value into the token pointed to by token.
+func decodePageToken(value string, token interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return e.InvalidPageTokenError
+	}
+	if err := json.Unmarshal(data, token); err != nil {
+		return e.InvalidPageTokenError
+	}
+	return nil
+}
+
+// listQuery is a validated ListPostsRequest.
+type listQuery struct {
+	pageSize int
+	author   string
+	tag      string
+	from     time.Time
+	to       time.Time
+	orderBy  string
+	desc     bool
+	cursor   *pageToken
+}
+
+func parseOrderBy(orderBy string) (string, bool, error) {
+	parts := strings.Fields(strings.ToLower(orderBy))
+	if len(parts) == 0 {
+		return orderByPostId, false, nil
+	}
+	if len(parts) > 2 {
+		return "", false, e.InvalidOrderByError
+	}
+	switch parts[0] {
+	case orderByPostId, orderByTitle, orderByAuthor, orderByPublicationDate:
+	default:
+		return "", false, e.InvalidOrderByError
+	}
+	if len(parts) == 1 || parts[1] == "asc" {
+		return parts[0], false, nil
+	}
+	if parts[1] == "desc" {
+		return parts[0], true, nil
+	}
+	return "", false, e.InvalidOrderByError
+}
+
+func parseDateFilter(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if validateDateFormat(date) != nil {
+		return time.Time{}, e.InvalidPublicationDateError
+	}
+	parsed, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, e.InvalidPublicationDateError
+	}
+	return parsed, nil
+}
+
+// normalizePageSize applies the default and maximum page sizes.
+func normalizePageSize(pageSize int32) (int, error) {
+	if pageSize < 0 {
+		return 0, e.InvalidPageSizeError
+	}
+	if pageSize == 0 {
+		return defaultPageSize, nil
+	}
+	if pageSize > maxPageSize {
+		return maxPageSize, nil
+	}
+	return int(pageSize), nil
+}
+
+func newListQuery(in *posts.ListPostsRequest) (*listQuery, error) {
+	query := &listQuery{
+		author: in.Author,
+		tag:    strings.TrimSpace(in.Tag),
+	}
+
+	var err error
+	if query.pageSize, err = normalizePageSize(in.PageSize); err != nil {
+		return nil, err
+	}
+	if query.orderBy, query.desc, err = parseOrderBy(in.OrderBy); err != nil {
+		return nil, err
+	}
+	if query.from, err = parseDateFilter(in.PublicationDateFrom); err != nil {
+		return nil, err
+	}
+	if query.to, err = parseDateFilter(in.PublicationDateTo); err != nil {
+		return nil, err
+	}
+	if !query.from.IsZero() && !query.to.IsZero() && query.from.After(query.to) {
+		return nil, e.InvalidDateRangeError
+	}
+
+	if in.PageToken != "" {
+		query.cursor = &pageToken{}
+		if err := decodePageToken(in.PageToken, query.cursor); err != nil {
+			return nil, err
+		}
+		if query.cursor.Request != query.fingerprint() {
+			return nil, e.InvalidPageTokenError
+		}
+	}
+	return query, nil
+}
+
+// fingerprint identifies the filters and ordering of the query, but not the
+// page size, which callers may change between pages.
+func (q *listQuery) fingerprint() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%t",
+		q.author, q.tag, q.from.Format(dateLayout), q.to.Format(dateLayout), q.orderBy, q.desc)
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func (q *listQuery) filter() d.PostFilter {
+	return d.PostFilter{
+		Author:        q.author,
+		Tag:           q.tag,
+		PublishedFrom: q.from,
+		PublishedTo:   q.to,
+	}
+}
+
+// sortKey returns a string that orders posts lexically by the query's field.
+func (q *listQuery) sortKey(post *m.Post) string {
+	switch q.orderBy {
+	case orderByTitle:
+		return post.Title
+	case orderByAuthor:
+		return post.Author
+	case orderByPublicationDate:
+		published, err := time.Parse(dateLayout, post.PublicationDate)
+		if err != nil {
+			return ""
+		}
+		return published.Format(sortDateLayout)
+	default:
+		return fmt.Sprintf("%020d", post.PostId)
+	}
+}
+
+// less orders by sort key, breaking ties by post id so the order is total.
+func (q *listQuery) less(keyA string, idA uint64, keyB string, idB uint64) bool {
+	if keyA != keyB {
+		return (keyA < keyB) != q.desc
+	}
+	if idA == idB {
+		return false
+	}
+	return (idA < idB) != q.desc
+}
+
+// before reports whether post a sorts before post b.
+func (q *listQuery) before(a *m.Post, b *m.Post) bool {
+	return q.less(q.sortKey(a), a.PostId, q.sortKey(b), b.PostId)
+}
+
+func (q *listQuery) afterCursor(post *m.Post) bool {
+	if q.cursor == nil {
+		return true
+	}
+	return q.less(q.cursor.Key, q.cursor.PostId, q.sortKey(post), post.PostId)
+}
+
+// pageHeap is a heap of posts with the one sorting last in the query's
+// order on top.
+type pageHeap struct {
+	query *listQuery
+	posts []*m.Post
+}
+
+func (h *pageHeap) Len() int           { return len(h.posts) }
+func (h *pageHeap) Less(i, j int) bool { return h.query.before(h.posts[j], h.posts[i]) }
+func (h *pageHeap) Swap(i, j int)      { h.posts[i], h.posts[j] = h.posts[j], h.posts[i] }
+func (h *pageHeap) Push(x interface{}) { h.posts = append(h.posts, x.(*m.Post)) }
+
+func (h *pageHeap) Pop() interface{} {
+	last := h.posts[len(h.posts)-1]
+	h.posts = h.posts[:len(h.posts)-1]
+	return last
+}
+
+func (s *PostsService) ListPosts(ctx context.Context, in *posts.ListPostsRequest) (*posts.ListPostsResponse, error) {
+	ctx, span := startSpan(ctx, "ListPosts")
+	defer span.End()
+	query, err := newListQuery(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*m.Post, 0, query.pageSize+1)
+	// Collect one post more than a page to know whether there is another.
+	collect := func(post *m.Post) bool {
+		matched = append(matched, post)
+		return len(matched) <= query.pageSize
+	}
+	switch {
+	case query.orderBy == orderByPostId && !query.desc:
+		// The DAO already finds posts in id order, so resume right after
+		// the cursor.
+		var afterId uint64
+		if query.cursor != nil {
+			afterId = query.cursor.PostId
+		}
+		err = blog.FindPosts(query.filter(), afterId, collect)
+	case query.orderBy == orderByPublicationDate:
+		var after *d.PostCursor
+		if query.cursor != nil {
+			after = &d.PostCursor{PostId: query.cursor.PostId}
+			// Posts with unparseable dates have an empty key.
+			after.PublicationDate, _ = time.Parse(sortDateLayout, query.cursor.Key)
+		}
+		err = blog.FindPostsByPublicationDate(query.filter(), query.desc, after, collect)
+	case query.orderBy == orderByPostId:
+		var beforeId uint64
+		if query.cursor != nil {
+			beforeId = query.cursor.PostId
+		}
+		err = blog.FindPostsDescending(query.filter(), beforeId, collect)
+	default:
+		// Only the first posts after the cursor are kept, in a heap whose
+		// top is the one to drop when another sorts before it.
+		page := &pageHeap{query: query}
+		err = blog.FindPosts(query.filter(), 0, func(post *m.Post) bool {
+			if query.afterCursor(post) {
+				heap.Push(page, post)
+				if page.Len() > query.pageSize+1 {
+					heap.Pop(page)
+				}
+			}
+			return true
+		})
+		matched = page.posts
+		sort.Slice(matched, func(i, j int) bool { return query.before(matched[i], matched[j]) })
+	}
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &posts.ListPostsResponse{}
+	if len(matched) > query.pageSize {
+		matched = matched[:query.pageSize]
+		last := matched[len(matched)-1]
+		response.NextPageToken = encodePageToken(pageToken{
+			Request: query.fingerprint(),
+			Key:     query.sortKey(last),
+			PostId:  last.PostId,
+		})
+	}
+	response.Posts = make([]*posts.PostResponse, 0, len(matched))
+	for _, post := range matched {
+		response.Posts = append(response.Posts, convertToPostResponse(post))
+	}
+	return response, nil
+}
@@ -1,30 +1,103 @@
 package services
 
 import (
+	"cloudbees/auth"
 	d "cloudbees/dao"
 	e "cloudbees/errors"
 	"cloudbees/genproto/posts"
 	m "cloudbees/models"
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// actorMetadataKey is the request metadata naming who makes a change when
+// callers don't authenticate.
+const actorMetadataKey = "x-actor"
+
+// blogMetadataKey is the request metadata naming the blog a request is for.
+// Requests without it are for the default blog.
+const blogMetadataKey = "x-blog"
+
+// maxUpdateAttempts bounds how often UpdatePost retries an update without an
+// expected version that lost a race with a concurrent update.
+const maxUpdateAttempts = 5
+
 type PostsService struct {
 	posts.UnimplementedBlogServiceServer
-	postsDao *d.PostDAO
+	blogs *d.BlogRepository
+}
+
+// NewPostsService returns a PostsService keeping the posts of the default
+// blog in dao and those of other blogs in memory.
+func NewPostsService(dao d.PostRepository) *PostsService {
+	return NewBlogPostsService(d.NewBlogRepository(dao))
 }
 
-func NewPostsService(dao *d.PostDAO) *PostsService {
+func NewBlogPostsService(blogs *d.BlogRepository) *PostsService {
 	return &PostsService{
-		postsDao: dao,
+		blogs: blogs,
 	}
 }
 
+// blogId returns the blog the request is for.
+func blogId(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(blogMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return d.DefaultBlog
+}
+
+// blog returns the posts of the blog the request is for, or a status error.
+func (s *PostsService) blog(ctx context.Context) (d.PostRepository, error) {
+	id := blogId(ctx)
+	blog, err := s.blogs.Blog(id)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("blog", id))
+	return d.WithContext(ctx, blog), nil
+}
+
+// actor returns who makes the request, for the revision history: the
+// authenticated principal, or else whoever the request metadata names.
+func actor(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return principal.Subject
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(actorMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// stamp records who changes the post and when.
+func stamp(ctx context.Context, post *m.Post) {
+	post.UpdatedBy = actor(ctx)
+	post.UpdatedAt = time.Now().UTC()
+}
+
+// timestamp converts t to a protobuf timestamp, leaving zero times unset.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
 func convertToPostResponse(post *m.Post) *posts.PostResponse {
 	return &posts.PostResponse{
 		PostId:          post.PostId,
@@ -33,7 +106,33 @@ func convertToPostResponse(post *m.Post) *posts.PostResponse {
 		Author:          post.Author,
 		PublicationDate: post.PublicationDate,
 		Tags:            post.Tags,
+		Version:         post.Version,
+		UpdatedBy:       post.UpdatedBy,
+		UpdateTime:      timestamp(post.UpdatedAt),
+		DeletedBy:       post.DeletedBy,
+		DeleteTime:      timestamp(post.DeletedAt),
+	}
+}
+
+// daoStatusError converts an error returned by the post repository into a
+// gRPC status error.
+func daoStatusError(err error) error {
+	if errors.Is(err, e.EnitityNotFoundError) || errors.Is(err, e.BlogNotFoundError) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, e.VersionConflictError) {
+		return status.Error(codes.Aborted, err.Error())
 	}
+	if errors.Is(err, e.EntityAlreadyExistsError) || errors.Is(err, e.BlogAlreadyExistsError) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, e.InvalidBlogIdError) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, e.DefaultBlogDeletionError) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
 }
 
 func validateDateFormat(date string) error {
@@ -53,9 +152,6 @@ func validateDateFormat(date string) error {
 
 func ValidateCreatePostRequest(in *posts.CreatePostRequest) error {
 
-	if in.PostId == 0 {
-		return e.PostIdMissingError
-	}
 	if in.Title == "" {
 		return e.TitleMissingError
 	}
@@ -80,6 +176,20 @@ func ValidateCreatePostRequest(in *posts.CreatePostRequest) error {
 	return nil
 }
 
+func ValidateUpsertPostRequest(in *posts.UpsertPostRequest) error {
+	if in.PostId == 0 {
+		return e.PostIdMissingError
+	}
+	return ValidateCreatePostRequest(&posts.CreatePostRequest{
+		PostId:          in.PostId,
+		Title:           in.Title,
+		Content:         in.Content,
+		Author:          in.Author,
+		PublicationDate: in.PublicationDate,
+		Tags:            in.Tags,
+	})
+}
+
 // CleanTags cleans and validates tags.
 func CleanTags(tags []string) []string {
 	cleanedTags := make([]string, 0, len(tags))
@@ -93,6 +203,15 @@ func CleanTags(tags []string) []string {
 }
 
 func (s *PostsService) CreatePost(ctx context.Context, in *posts.CreatePostRequest) (*posts.PostResponse, error) {
+	ctx, span := startSpan(ctx, "CreatePost")
+	defer span.End()
+	author, err := postAuthor(ctx, in.Author)
+	if err != nil {
+		return nil, err
+	}
+	in = proto.Clone(in).(*posts.CreatePostRequest)
+	in.Author = author
+
 	// Validate input fields
 	if err := ValidateCreatePostRequest(in); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
@@ -104,7 +223,7 @@ func (s *PostsService) CreatePost(ctx context.Context, in *posts.CreatePostReque
 		return nil, status.Error(codes.InvalidArgument, e.TagsMissingError.Error())
 	}
 
-	// Create post object
+	// Create post object, the DAO allocates an id when PostId is 0
 	post := &m.Post{
 		PostId:          in.PostId,
 		Title:           in.Title,
@@ -113,26 +232,184 @@ func (s *PostsService) CreatePost(ctx context.Context, in *posts.CreatePostReque
 		PublicationDate: in.PublicationDate,
 		Tags:            cleanedTags,
 	}
+	stamp(ctx, post)
 
 	// Persist post to database
-	err := s.postsDao.Create(post)
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = blog.Create(post)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, daoStatusError(err)
 	}
 
 	// Convert post to response format and return
 	return convertToPostResponse(post), nil
 }
 
+func (s *PostsService) UpsertPost(ctx context.Context, in *posts.UpsertPostRequest) (*posts.PostResponse, error) {
+	ctx, span := startSpan(ctx, "UpsertPost")
+	defer span.End()
+	author, err := postAuthor(ctx, in.Author)
+	if err != nil {
+		return nil, err
+	}
+	in = proto.Clone(in).(*posts.UpsertPostRequest)
+	in.Author = author
+
+	if err := ValidateUpsertPostRequest(in); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	cleanedTags := CleanTags(in.Tags)
+	if len(cleanedTags) == 0 {
+		return nil, status.Error(codes.InvalidArgument, e.TagsMissingError.Error())
+	}
+
+	post := &m.Post{
+		PostId:          in.PostId,
+		Title:           in.Title,
+		Content:         in.Content,
+		Author:          in.Author,
+		PublicationDate: in.PublicationDate,
+		Tags:            cleanedTags,
+		Version:         in.ExpectedVersion,
+	}
+	stamp(ctx, post)
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := blog.Read(in.PostId)
+	if err != nil && !errors.Is(err, e.EnitityNotFoundError) {
+		return nil, daoStatusError(err)
+	}
+	if err == nil {
+		if err := authorizeAuthor(ctx, existing.Author); err != nil {
+			return nil, err
+		}
+		// The post must still be the one whose author was checked.
+		if post.Version == 0 {
+			post.Version = existing.Version
+		}
+	} else if _, authenticated := auth.FromContext(ctx); authenticated && post.Version == 0 {
+		// The post must still be missing, rather than one another author
+		// created since, which Upsert would replace unchecked.
+		err = blog.Create(post)
+		if err != nil {
+			return nil, daoStatusError(err)
+		}
+		return convertToPostResponse(post), nil
+	}
+	if err := blog.Upsert(post); err != nil {
+		return nil, daoStatusError(err)
+	}
+	return convertToPostResponse(post), nil
+}
+
 func (s *PostsService) GetPost(ctx context.Context, in *posts.GetPostRequest) (*posts.PostResponse, error) {
-	post, err := s.postsDao.Read(in.PostId)
+	ctx, span := startSpan(ctx, "GetPost")
+	defer span.End()
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	post, err := blog.Read(in.PostId)
 	if err != nil {
-		return nil, status.Error(codes.NotFound, err.Error())
+		return nil, daoStatusError(err)
 	}
 	return convertToPostResponse(post), nil
 }
 
+// updateMaskPaths are the UpdatePostRequest fields an update_mask may name.
+var updateMaskPaths = map[string]bool{
+	"title":            true,
+	"content":          true,
+	"author":           true,
+	"publication_date": true,
+	"tags":             true,
+}
+
+// updateMask returns the set of fields named by the request's update_mask,
+// or nil when the request has no mask.
+func updateMask(in *posts.UpdatePostRequest) (map[string]bool, error) {
+	if len(in.GetUpdateMask().GetPaths()) == 0 {
+		return nil, nil
+	}
+	mask := make(map[string]bool)
+	for _, path := range in.UpdateMask.Paths {
+		if path == "*" {
+			for field := range updateMaskPaths {
+				mask[field] = true
+			}
+			continue
+		}
+		if !updateMaskPaths[path] {
+			return nil, fmt.Errorf("%w: unknown field %q", e.InvalidUpdateMaskError, path)
+		}
+		mask[path] = true
+	}
+	return mask, nil
+}
+
+func ValidateUpdatePostRequest(in *posts.UpdatePostRequest) error {
+	mask, err := updateMask(in)
+	if err != nil {
+		return err
+	}
+	if mask == nil {
+		if in.PublicationDate != "" && validateDateFormat(in.PublicationDate) != nil {
+			return e.InvalidPublicationDateError
+		}
+		return nil
+	}
+
+	// Masked fields are set as sent, so the fields every post needs can't be
+	// cleared. Tags are the exception.
+	if mask["title"] && in.Title == "" {
+		return e.TitleMissingError
+	}
+	if mask["content"] && in.Content == "" {
+		return e.ContentMissingError
+	}
+	if mask["author"] && in.Author == "" {
+		return e.AuthorMissingError
+	}
+	if mask["publication_date"] {
+		if in.PublicationDate == "" {
+			return e.PublicationDateMissingError
+		}
+		if validateDateFormat(in.PublicationDate) != nil {
+			return e.InvalidPublicationDateError
+		}
+	}
+	return nil
+}
+
+// updatePostFields applies the update to post: the masked fields when the
+// request has an update_mask, the non-empty ones otherwise.
 func updatePostFields(post *m.Post, in *posts.UpdatePostRequest) {
+	mask, _ := updateMask(in)
+	if mask != nil {
+		if mask["title"] {
+			post.Title = in.Title
+		}
+		if mask["content"] {
+			post.Content = in.Content
+		}
+		if mask["author"] {
+			post.Author = in.Author
+		}
+		if mask["publication_date"] {
+			post.PublicationDate = in.PublicationDate
+		}
+		if mask["tags"] {
+			post.Tags = CleanTags(in.Tags)
+		}
+		return
+	}
+
 	if in.Title != "" {
 		post.Title = in.Title
 	}
@@ -151,26 +428,71 @@ func updatePostFields(post *m.Post, in *posts.UpdatePostRequest) {
 }
 
 func (s *PostsService) UpdatePost(ctx context.Context, in *posts.UpdatePostRequest) (*posts.PostResponse, error) {
-	post, err := s.postsDao.Read(in.PostId)
-	if err != nil {
-		return nil, status.Error(codes.NotFound, err.Error())
+	ctx, span := startSpan(ctx, "UpdatePost")
+	defer span.End()
+	if err := ValidateUpdatePostRequest(in); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	if validateDateFormat(in.PublicationDate) != nil {
-		return nil, status.Error(codes.InvalidArgument, e.InvalidPublicationDateError.Error())
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
 	}
-	updatePostFields(post, in)
 
-	err = s.postsDao.Update(post)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	for attempt := 1; ; attempt++ {
+		post, err := blog.Read(in.PostId)
+		if err != nil {
+			return nil, daoStatusError(err)
+		}
+		if in.ExpectedVersion != 0 && in.ExpectedVersion != post.Version {
+			return nil, daoStatusError(e.VersionConflictError)
+		}
+		if err := authorizeAuthor(ctx, post.Author); err != nil {
+			return nil, err
+		}
+
+		// The DAO hands out copies, so the changes only reach the store
+		// once Update has checked the post is still at the version we read.
+		updatePostFields(post, in)
+		if err := authorizeAuthor(ctx, post.Author); err != nil {
+			return nil, err
+		}
+		stamp(ctx, post)
+
+		err = blog.Update(post)
+		if errors.Is(err, e.VersionConflictError) && in.ExpectedVersion == 0 && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, daoStatusError(err)
+		}
+		return convertToPostResponse(post), nil
 	}
-	return convertToPostResponse(post), nil
 }
 
 func (s *PostsService) DeletePost(ctx context.Context, in *posts.DeletePostRequest) (*posts.DeletePostResponse, error) {
-	err := s.postsDao.Delete(in.PostId)
+	ctx, span := startSpan(ctx, "DeletePost")
+	defer span.End()
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version := in.ExpectedVersion
+	if _, authenticated := auth.FromContext(ctx); authenticated {
+		post, err := blog.Read(in.PostId)
+		if err != nil {
+			return nil, daoStatusError(err)
+		}
+		if err := authorizeAuthor(ctx, post.Author); err != nil {
+			return nil, err
+		}
+		// The post must still be the one whose author was checked.
+		if version == 0 {
+			version = post.Version
+		}
+	}
+	err = blog.Trash(in.PostId, version, actor(ctx), time.Now().UTC())
 	if err != nil {
-		return nil, status.Error(codes.NotFound, err.Error())
+		return nil, daoStatusError(err)
 	}
 	return &posts.DeletePostResponse{
 		Message: "Post deleted successfully",
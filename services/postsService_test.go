@@ -0,0 +1,172 @@
+package services
+
+import (
+	"cloudbees/auth"
+	d "cloudbees/dao"
+	e "cloudbees/errors"
+	"cloudbees/genproto/posts"
+	m "cloudbees/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakePostRepository is a d.PostRepository whose writes fail with err.
+type fakePostRepository struct {
+	posts map[uint64]*m.Post
+	err   error
+}
+
+func (r *fakePostRepository) Create(post *m.Post) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.posts[post.PostId] = post
+	return nil
+}
+
+func (r *fakePostRepository) Read(id uint64) (*m.Post, error) {
+	post, exists := r.posts[id]
+	if !exists {
+		return nil, e.EnitityNotFoundError
+	}
+	return post, nil
+}
+
+func (r *fakePostRepository) Update(post *m.Post) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.posts[post.PostId] = post
+	return nil
+}
+
+func (r *fakePostRepository) Delete(id uint64) error {
+	if r.err != nil {
+		return r.err
+	}
+	delete(r.posts, id)
+	return nil
+}
+
+func (r *fakePostRepository) Upsert(post *m.Post) error {
+	return r.Update(post)
+}
+
+func (r *fakePostRepository) DeleteVersion(id uint64, version uint64) error {
+	return r.Delete(id)
+}
+
+func (r *fakePostRepository) Count() (int, error) {
+	return len(r.posts), nil
+}
+
+func (r *fakePostRepository) Scan(afterId uint64, fn func(post *m.Post) bool) error {
+	return nil
+}
+
+func (r *fakePostRepository) FindPosts(filter d.PostFilter, afterId uint64, fn func(post *m.Post) bool) error {
+	return nil
+}
+
+func (r *fakePostRepository) FindPostsDescending(filter d.PostFilter, beforeId uint64, fn func(post *m.Post) bool) error {
+	return nil
+}
+
+func (r *fakePostRepository) FindPostsByPublicationDate(filter d.PostFilter, desc bool, after *d.PostCursor, fn func(post *m.Post) bool) error {
+	return nil
+}
+
+func (r *fakePostRepository) Search(query string, offset int, limit int) ([]d.SearchHit, int, error) {
+	return nil, 0, nil
+}
+
+func (r *fakePostRepository) ListRevisions(id uint64) ([]*m.PostRevision, error) {
+	return nil, e.EnitityNotFoundError
+}
+
+func (r *fakePostRepository) ReadRevision(id uint64, version uint64) (*m.PostRevision, error) {
+	return nil, e.EnitityNotFoundError
+}
+
+func (r *fakePostRepository) Trash(id uint64, version uint64, deletedBy string, deletedAt time.Time) error {
+	return r.Delete(id)
+}
+
+func (r *fakePostRepository) Restore(id uint64) error {
+	return e.EnitityNotFoundError
+}
+
+func (r *fakePostRepository) Purge(id uint64) error {
+	return e.EnitityNotFoundError
+}
+
+func (r *fakePostRepository) ScanDeleted(afterId uint64, fn func(post *m.Post) bool) error {
+	return nil
+}
+
+func TestCreatePostStorageFailure(t *testing.T) {
+	service := NewPostsService(&fakePostRepository{
+		posts: map[uint64]*m.Post{},
+		err:   errors.New("disk full"),
+	})
+
+	_, err := service.CreatePost(context.Background(), &posts.CreatePostRequest{
+		PostId:          1,
+		Title:           "Test Post",
+		Content:         "Test Content",
+		Author:          "Test Author",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected %v, got %v", codes.Internal, err)
+	}
+}
+
+// racingPostRepository is a PostDAO where another author creates the rival
+// post right after a Read misses it.
+type racingPostRepository struct {
+	*d.PostDAO
+	rival *m.Post
+}
+
+func (r *racingPostRepository) Read(id uint64) (*m.Post, error) {
+	post, err := r.PostDAO.Read(id)
+	if errors.Is(err, e.EnitityNotFoundError) && r.rival != nil && r.rival.PostId == id {
+		r.PostDAO.Create(r.rival)
+		r.rival = nil
+	}
+	return post, err
+}
+
+func TestUpsertPostDoesNotReplacePostCreatedSinceRead(t *testing.T) {
+	repo := &racingPostRepository{PostDAO: d.NewPostDAO(), rival: &m.Post{
+		PostId:          1,
+		Title:           "Bob's Post",
+		Content:         "Content",
+		Author:          "bob",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	}}
+	service := NewPostsService(repo)
+	alice := auth.NewContext(context.Background(), &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleAuthor}})
+
+	_, err := service.UpsertPost(alice, &posts.UpsertPostRequest{
+		PostId:          1,
+		Title:           "Alice's Post",
+		Content:         "Content",
+		PublicationDate: "01-01-2024",
+		Tags:            []string{"test"},
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected %v for a post created since it was read, got %v", codes.AlreadyExists, err)
+	}
+	if post, _ := repo.PostDAO.Read(1); post.Author != "bob" || post.Title != "Bob's Post" {
+		t.Fatalf("expected bob's post to be kept, got %+v", post)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	e "cloudbees/errors"
+	"cloudbees/genproto/posts"
+	m "cloudbees/models"
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// revisionPageToken is the decoded form of a ListPostRevisions page token.
+type revisionPageToken struct {
+	PostId uint64 `json:"p"`
+	// BeforeVersion is the version of the last revision on the previous page.
+	BeforeVersion uint64 `json:"v"`
+}
+
+func convertToPostRevision(revision *m.PostRevision) *posts.PostRevision {
+	return &posts.PostRevision{
+		PostId:          revision.Post.PostId,
+		Version:         revision.Post.Version,
+		Title:           revision.Post.Title,
+		Content:         revision.Post.Content,
+		Author:          revision.Post.Author,
+		PublicationDate: revision.Post.PublicationDate,
+		Tags:            revision.Post.Tags,
+		UpdatedBy:       revision.Post.UpdatedBy,
+		UpdateTime:      timestamp(revision.Post.UpdatedAt),
+		ChangedFields:   revision.ChangedFields,
+	}
+}
+
+func (s *PostsService) ListPostRevisions(ctx context.Context, in *posts.ListPostRevisionsRequest) (*posts.ListPostRevisionsResponse, error) {
+	ctx, span := startSpan(ctx, "ListPostRevisions")
+	defer span.End()
+	pageSize, err := normalizePageSize(in.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	token := revisionPageToken{PostId: in.PostId}
+	if in.PageToken != "" {
+		if err := decodePageToken(in.PageToken, &token); err != nil || token.PostId != in.PostId {
+			return nil, status.Error(codes.InvalidArgument, e.InvalidPageTokenError.Error())
+		}
+	}
+
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := blog.ListRevisions(in.PostId)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+
+	response := &posts.ListPostRevisionsResponse{}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		if token.BeforeVersion != 0 && revision.Post.Version >= token.BeforeVersion {
+			continue
+		}
+		if len(response.Revisions) == pageSize {
+			last := response.Revisions[pageSize-1]
+			response.NextPageToken = encodePageToken(revisionPageToken{PostId: in.PostId, BeforeVersion: last.Version})
+			break
+		}
+		response.Revisions = append(response.Revisions, convertToPostRevision(revision))
+	}
+	return response, nil
+}
+
+func (s *PostsService) GetPostRevision(ctx context.Context, in *posts.GetPostRevisionRequest) (*posts.PostRevision, error) {
+	ctx, span := startSpan(ctx, "GetPostRevision")
+	defer span.End()
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := blog.ReadRevision(in.PostId, in.Version)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	return convertToPostRevision(revision), nil
+}
+
+// RevertPost writes the fields of an earlier revision over the post. The
+// revert is an update like any other and is recorded as a new revision.
+func (s *PostsService) RevertPost(ctx context.Context, in *posts.RevertPostRequest) (*posts.PostResponse, error) {
+	ctx, span := startSpan(ctx, "RevertPost")
+	defer span.End()
+	if in.Version == 0 {
+		return nil, status.Error(codes.InvalidArgument, e.RevisionVersionMissingError.Error())
+	}
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := blog.ReadRevision(in.PostId, in.Version)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	return s.UpdatePost(ctx, &posts.UpdatePostRequest{
+		PostId:          in.PostId,
+		Title:           revision.Post.Title,
+		Content:         revision.Post.Content,
+		Author:          revision.Post.Author,
+		PublicationDate: revision.Post.PublicationDate,
+		Tags:            revision.Post.Tags,
+		ExpectedVersion: in.ExpectedVersion,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"*"}},
+	})
+}
+
+func (s *PostsService) DiffPostRevisions(ctx context.Context, in *posts.DiffPostRevisionsRequest) (*posts.DiffPostRevisionsResponse, error) {
+	ctx, span := startSpan(ctx, "DiffPostRevisions")
+	defer span.End()
+	if in.FromVersion == 0 || in.ToVersion == 0 {
+		return nil, status.Error(codes.InvalidArgument, e.RevisionVersionMissingError.Error())
+	}
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := blog.ReadRevision(in.PostId, in.FromVersion)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	to, err := blog.ReadRevision(in.PostId, in.ToVersion)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	return &posts.DiffPostRevisionsResponse{
+		Diff: unifiedDiff(
+			fmt.Sprintf("revision %d", in.FromVersion),
+			fmt.Sprintf("revision %d", in.ToVersion),
+			from.Post.Content,
+			to.Post.Content,
+		),
+	}, nil
+}
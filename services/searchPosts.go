@@ -0,0 +1,77 @@
+package services
+
+import (
+	e "cloudbees/errors"
+	"cloudbees/genproto/posts"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// searchPageToken is the decoded form of a SearchPosts page token. Search
+// results are ranked, so pages are addressed by offset.
+type searchPageToken struct {
+	Request string `json:"r"`
+	Offset  int    `json:"o"`
+}
+
+func searchFingerprint(query string) string {
+	h := fnv.New64a()
+	h.Write([]byte(query))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func (s *PostsService) SearchPosts(ctx context.Context, in *posts.SearchPostsRequest) (*posts.SearchPostsResponse, error) {
+	ctx, span := startSpan(ctx, "SearchPosts")
+	defer span.End()
+	query := strings.TrimSpace(in.Query)
+	if query == "" {
+		return nil, status.Error(codes.InvalidArgument, e.SearchQueryMissingError.Error())
+	}
+	pageSize, err := normalizePageSize(in.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	token := searchPageToken{Request: searchFingerprint(query)}
+	if in.PageToken != "" {
+		if err := decodePageToken(in.PageToken, &token); err != nil || token.Request != searchFingerprint(query) || token.Offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, e.InvalidPageTokenError.Error())
+		}
+	}
+
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hits, total, err := blog.Search(query, token.Offset, pageSize)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &posts.SearchPostsResponse{
+		Results:   make([]*posts.SearchResult, 0, len(hits)),
+		TotalSize: int32(total),
+	}
+	for _, hit := range hits {
+		result := &posts.SearchResult{
+			Post:    convertToPostResponse(hit.Post),
+			Score:   hit.Score,
+			Snippet: hit.Snippet,
+		}
+		for _, highlight := range hit.Highlights {
+			result.Highlights = append(result.Highlights, &posts.TextRange{
+				Start: int32(highlight[0]),
+				End:   int32(highlight[1]),
+			})
+		}
+		response.Results = append(response.Results, result)
+	}
+	if next := token.Offset + pageSize; next < total {
+		response.NextPageToken = encodePageToken(searchPageToken{Request: token.Request, Offset: next})
+	}
+	return response, nil
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every call, like the tracer of package tracing.
+func tracer() trace.Tracer {
+	return otel.Tracer("cloudbees/services")
+}
+
+// startSpan starts the span of a PostsService method, the parent of the
+// spans of the storage operations it makes with the returned context.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "PostsService."+method)
+}
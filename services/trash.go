@@ -0,0 +1,168 @@
+package services
+
+import (
+	d "cloudbees/dao"
+	e "cloudbees/errors"
+	"cloudbees/genproto/posts"
+	m "cloudbees/models"
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trashPageToken is the decoded form of a ListDeletedPosts page token.
+type trashPageToken struct {
+	PostId uint64 `json:"i"`
+}
+
+func (s *PostsService) ListDeletedPosts(ctx context.Context, in *posts.ListDeletedPostsRequest) (*posts.ListDeletedPostsResponse, error) {
+	ctx, span := startSpan(ctx, "ListDeletedPosts")
+	defer span.End()
+	pageSize, err := normalizePageSize(in.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var token trashPageToken
+	if in.PageToken != "" {
+		if err := decodePageToken(in.PageToken, &token); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authors only see their own deleted posts, editors everyone's.
+	deleted := make([]*m.Post, 0, pageSize+1)
+	err = blog.ScanDeleted(token.PostId, func(post *m.Post) bool {
+		if !writesAuthor(ctx, post.Author) {
+			return true
+		}
+		deleted = append(deleted, post)
+		return len(deleted) <= pageSize
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &posts.ListDeletedPostsResponse{}
+	if len(deleted) > pageSize {
+		deleted = deleted[:pageSize]
+		response.NextPageToken = encodePageToken(trashPageToken{PostId: deleted[pageSize-1].PostId})
+	}
+	response.Posts = make([]*posts.PostResponse, 0, len(deleted))
+	for _, post := range deleted {
+		response.Posts = append(response.Posts, convertToPostResponse(post))
+	}
+	return response, nil
+}
+
+func (s *PostsService) RestorePost(ctx context.Context, in *posts.RestorePostRequest) (*posts.PostResponse, error) {
+	ctx, span := startSpan(ctx, "RestorePost")
+	defer span.End()
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := readDeleted(blog, in.PostId)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	if err := authorizeAuthor(ctx, deleted.Author); err != nil {
+		return nil, err
+	}
+	if err := blog.Restore(in.PostId); err != nil {
+		return nil, daoStatusError(err)
+	}
+	post, err := blog.Read(in.PostId)
+	if err != nil {
+		return nil, daoStatusError(err)
+	}
+	return convertToPostResponse(post), nil
+}
+
+func (s *PostsService) PurgePost(ctx context.Context, in *posts.PurgePostRequest) (*posts.PurgePostResponse, error) {
+	ctx, span := startSpan(ctx, "PurgePost")
+	defer span.End()
+	blog, err := s.blog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := blog.Purge(in.PostId); err != nil {
+		return nil, daoStatusError(err)
+	}
+	return &posts.PurgePostResponse{
+		Message: "Post purged successfully",
+	}, nil
+}
+
+// PurgeDeletedBefore permanently deletes the posts of every blog moved to the
+// trash before the given time and returns how many it purged.
+func (s *PostsService) PurgeDeletedBefore(before time.Time) (int, error) {
+	purged := 0
+	for _, id := range s.blogs.Blogs() {
+		blog, err := s.blogs.Blog(id)
+		if errors.Is(err, e.BlogNotFoundError) {
+			// Deleted since we listed the blogs.
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		count, err := purgeDeletedBefore(blog, before)
+		purged += count
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+func purgeDeletedBefore(blog d.PostRepository, before time.Time) (int, error) {
+	var expired []uint64
+	err := blog.ScanDeleted(0, func(post *m.Post) bool {
+		if post.DeletedAt.Before(before) {
+			expired = append(expired, post.PostId)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, id := range expired {
+		// The post may have been restored or purged since the scan.
+		err := blog.Purge(id)
+		if errors.Is(err, e.EnitityNotFoundError) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunPurger purges the posts that have been in the trash for longer than
+// retention every interval, until ctx is done. Failures are passed to
+// onError and retried on the next run.
+func (s *PostsService) RunPurger(ctx context.Context, retention time.Duration, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.PurgeDeletedBefore(time.Now().Add(-retention)); err != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
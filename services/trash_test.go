@@ -0,0 +1,79 @@
+package services
+
+import (
+	"cloudbees/auth"
+	d "cloudbees/dao"
+	"cloudbees/genproto/posts"
+	m "cloudbees/models"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeDeletedBefore(t *testing.T) {
+	dao, err := d.NewFilePostDAO(filepath.Join(t.TempDir(), "posts.wal"))
+	if err != nil {
+		t.Fatalf("failed to open dao: %v", err)
+	}
+	defer dao.Close()
+	service := NewPostsService(dao)
+
+	now := time.Now()
+	for id := uint64(1); id <= 3; id++ {
+		dao.Create(&m.Post{PostId: id, Title: "Title", Tags: []string{"tag"}})
+	}
+	dao.Trash(1, 0, "", now.Add(-2*time.Hour))
+	dao.Trash(2, 0, "", now)
+
+	purged, err := service.PurgeDeletedBefore(now.Add(-time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected 1 post to be purged, got %d, %v", purged, err)
+	}
+	if err := dao.Restore(1); err == nil {
+		t.Fatalf("expected post 1 to be purged")
+	}
+	if err := dao.Restore(2); err != nil {
+		t.Fatalf("expected post 2 to stay in the trash, got %v", err)
+	}
+}
+
+func TestListDeletedPostsOfAuthors(t *testing.T) {
+	dao := d.NewPostDAO()
+	service := NewPostsService(dao)
+	for id, author := range map[uint64]string{1: "alice", 2: "bob", 3: "alice"} {
+		dao.Create(&m.Post{PostId: id, Title: "Title", Author: author, Tags: []string{"tag"}})
+		dao.Trash(id, 0, author, time.Now())
+	}
+
+	tests := []struct {
+		name  string
+		roles []auth.Role
+		ids   []uint64
+	}{
+		{"author", []auth.Role{auth.RoleAuthor}, []uint64{1, 3}},
+		{"editor", []auth.Role{auth.RoleEditor}, []uint64{1, 2, 3}},
+	}
+	for _, test := range tests {
+		ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "alice", Roles: test.roles})
+		var ids []uint64
+		request := &posts.ListDeletedPostsRequest{PageSize: 1}
+		for {
+			response, err := service.ListDeletedPosts(ctx, request)
+			if err != nil {
+				t.Fatalf("%s: failed to list deleted posts: %v", test.name, err)
+			}
+			for _, post := range response.Posts {
+				ids = append(ids, post.PostId)
+			}
+			if response.NextPageToken == "" {
+				break
+			}
+			request.PageToken = response.NextPageToken
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(test.ids) {
+			t.Errorf("%s: expected deleted posts %v, got %v", test.name, test.ids, ids)
+		}
+	}
+}
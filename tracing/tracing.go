@@ -0,0 +1,109 @@
+// Package tracing traces the gRPC calls the server handles with
+// OpenTelemetry, continuing the W3C trace context callers send.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName names the server in the traces it exports.
+const ServiceName = "blog"
+
+// tracer returns the tracer of the current global TracerProvider: tracers
+// got before a provider is set only ever reach the first one set.
+func tracer() trace.Tracer {
+	return otel.Tracer("cloudbees/tracing")
+}
+
+// propagator reads the traceparent and tracestate metadata of calls.
+var propagator = propagation.TraceContext{}
+
+// NewTracerProvider returns a TracerProvider exporting spans in batches with
+// exporter. Traces callers sampled are sampled, and sampleRatio of the others.
+func NewTracerProvider(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+}
+
+// metadataCarrier lets the propagator read gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// UnaryServerInterceptor starts the span of every call, continuing the trace
+// of its traceparent metadata if it has any. It runs first, so the spans of
+// the other interceptors and of the handler are children of the call's.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = propagator.Extract(ctx, metadataCarrier(md))
+	service, method, _ := strings.Cut(strings.TrimPrefix(info.FullMethod, "/"), "/")
+	ctx, span := tracer().Start(ctx, service+"/"+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(method)))
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+	st := status.Convert(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(st.Code())))
+	if err != nil {
+		span.SetStatus(codes.Error, st.Message())
+	}
+	return resp, err
+}
+
+// Interceptor traces interceptor in a span of the name that ends once the
+// interceptor hands the call on, so it shows the time the interceptor takes
+// itself. Calls it refuses end the span with their error.
+func Interceptor(name string, interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		parent := trace.SpanFromContext(ctx)
+		ctx, span := tracer().Start(ctx, name)
+		handed := false
+		resp, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			handed = true
+			span.End()
+			// What comes next belongs to the call rather than the interceptor.
+			return handler(trace.ContextWithSpan(ctx, parent), req)
+		})
+		if !handed {
+			if err != nil {
+				span.SetStatus(codes.Error, status.Convert(err).Message())
+			}
+			span.End()
+		}
+		return resp, err
+	}
+}
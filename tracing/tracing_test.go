@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// recorder records the spans of every test.
+var recorder = tracetest.NewSpanRecorder()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	os.Exit(m.Run())
+}
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// spans returns the ended spans of the trace of traceparent by name.
+func spans(t *testing.T) map[string]sdktrace.ReadOnlySpan {
+	t.Helper()
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+// call makes a call through the interceptors with the traceparent metadata.
+func call(t *testing.T, method string, interceptors []grpc.UnaryServerInterceptor, handler grpc.UnaryHandler) error {
+	t.Helper()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceparent))
+	chained := handler
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], chained
+		chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, next)
+		}
+	}
+	_, err := chained(ctx, nil)
+	return err
+}
+
+func TestUnaryServerInterceptorContinuesTrace(t *testing.T) {
+	passing := Interceptor("Passing", func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	})
+	err := call(t, "/posts.BlogService/GetPost", []grpc.UnaryServerInterceptor{UnaryServerInterceptor, passing}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := otel.Tracer("test").Start(ctx, "Handler")
+		span.End()
+		return nil, status.Error(grpcCodes.NotFound, "no such post")
+	})
+	if status.Code(err) != grpcCodes.NotFound {
+		t.Fatalf("expected the handler's error, got %v", err)
+	}
+
+	spans := spans(t)
+	server, interceptor, handler := spans["posts.BlogService/GetPost"], spans["Passing"], spans["Handler"]
+	if server == nil || interceptor == nil || handler == nil {
+		t.Fatalf("expected spans of the call, the interceptor and the handler, got %v", spans)
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" || !server.Parent().IsRemote() {
+		t.Errorf("expected the call's span to continue the caller's, got parent %v", server.Parent())
+	}
+	if server.SpanKind() != trace.SpanKindServer || server.Status().Code != codes.Error {
+		t.Errorf("expected a failed server span, got kind %v and status %v", server.SpanKind(), server.Status())
+	}
+	if interceptor.Parent().SpanID() != server.SpanContext().SpanID() || handler.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("expected the interceptor and handler spans to be children of the call's")
+	}
+	if interceptor.EndTime().After(handler.StartTime()) {
+		t.Errorf("expected the interceptor span to end before the handler ran")
+	}
+}
+
+func TestInterceptorRecordsRefusals(t *testing.T) {
+	refusing := Interceptor("Refusing", func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, status.Error(grpcCodes.PermissionDenied, "not allowed")
+	})
+	err := call(t, "/posts.BlogService/DeletePost", []grpc.UnaryServerInterceptor{UnaryServerInterceptor, refusing}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatalf("expected the call to be refused before the handler")
+		return nil, nil
+	})
+	if status.Code(err) != grpcCodes.PermissionDenied {
+		t.Fatalf("expected the interceptor's error, got %v", err)
+	}
+	span := spans(t)["Refusing"]
+	if span == nil || span.Status().Code != codes.Error || span.Status().Description != "not allowed" {
+		t.Fatalf("expected the interceptor span to record the refusal, got %+v", span)
+	}
+}
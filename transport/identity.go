@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIdentity is who a verified client certificate was issued to.
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	URIs           []string
+	EmailAddresses []string
+	// Issuer is the common name of the certificate's issuer.
+	Issuer string
+}
+
+// Name returns the most specific name of the client: its common name, else
+// its first URI, DNS name or email address.
+func (id ClientIdentity) Name() string {
+	for _, names := range [][]string{{id.CommonName}, id.URIs, id.DNSNames, id.EmailAddresses} {
+		if len(names) > 0 && names[0] != "" {
+			return names[0]
+		}
+	}
+	return ""
+}
+
+// ClientIdentityFromContext returns the identity of the client of the call
+// handled with ctx. It returns false unless the client presented a
+// certificate that was verified against the client CA bundle.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ClientIdentity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ClientIdentity{}, false
+	}
+	return identityOf(info.State.VerifiedChains[0][0]), true
+}
+
+func identityOf(cert *x509.Certificate) ClientIdentity {
+	id := ClientIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Issuer:         cert.Issuer.CommonName,
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+	}
+	return id
+}
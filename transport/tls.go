@@ -0,0 +1,165 @@
+// Package transport secures the gRPC listener with TLS and tells handlers who
+// the client presenting a certificate is.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadCheckInterval bounds how often handshakes look for changed files.
+const reloadCheckInterval = time.Second
+
+// fileStamp tells whether a file changed since it was loaded.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertReloader serves the certificate, and verifies client certificates
+// against the CA bundle, currently in the files it was created with. Files
+// that changed are loaded again at the next handshake; until they load, the
+// previous ones stay in use.
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	// OnReload, when set, is called after every attempt to load changed files,
+	// with the error if they could not be loaded.
+	OnReload func(err error)
+
+	mu            sync.Mutex
+	checkInterval time.Duration
+	checked       time.Time
+	stamps        []fileStamp
+	config        *tls.Config
+}
+
+// NewCertReloader loads the certificate and key, and the client CA bundle if
+// clientCAFile isn't empty, failing if they can't be loaded.
+func NewCertReloader(certFile string, keyFile string, clientCAFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		clientCAFile:  clientCAFile,
+		checkInterval: reloadCheckInterval,
+	}
+	stamps, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(stamps); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+// TLSConfig returns the server TLS configuration, which picks up reloaded
+// files on every handshake.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.configForClient,
+	}
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+func (r *CertReloader) stat() ([]fileStamp, error) {
+	files := r.files()
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// load reads the files into a new configuration. Callers hold mu, except
+// while the reloader is created.
+func (r *CertReloader) load(stamps []fileStamp) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// GetConfigForClient replaces the configuration gRPC adds h2 to.
+		NextProtos: []string{"h2"},
+	}
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("cannot load client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("cannot load client CA bundle: no PEM certificates found")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.config = config
+	r.stamps = stamps
+	return nil
+}
+
+// reloadIfChanged loads the files again if they changed since they were
+// last loaded, at most every checkInterval.
+func (r *CertReloader) reloadIfChanged() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if now.Sub(r.checked) < r.checkInterval {
+		return
+	}
+	r.checked = now
+	stamps, err := r.stat()
+	if err == nil && sameStamps(stamps, r.stamps) {
+		return
+	}
+	if err == nil {
+		if err = r.load(stamps); err != nil {
+			// Broken files are tried again once they change.
+			r.stamps = stamps
+		}
+	}
+	if r.OnReload != nil {
+		r.OnReload(err)
+	}
+}
+
+func sameStamps(a []fileStamp, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CertReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.reloadIfChanged()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.config, nil
+}
@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA issues the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+var writes int
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf certificate.
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeTestFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	// Every write gets a modification time of its own, however coarse the
+	// file system's clock.
+	writes++
+	modTime := time.Now().Add(time.Duration(writes) * time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to touch %s: %v", path, err)
+	}
+}
+
+// serve serves the gRPC health service with the reloader's TLS configuration,
+// passing the client identity of every call to identities.
+func serve(t *testing.T, certs *CertReloader, identities chan<- ClientIdentity) string {
+	t.Helper()
+	record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id, _ := ClientIdentityFromContext(ctx)
+		identities <- id
+		return handler(ctx, req)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(certs.TLSConfig())), grpc.UnaryInterceptor(record))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go server.Serve(listen)
+	t.Cleanup(server.Stop)
+	return listen.Addr().String()
+}
+
+func check(address string, config *tls.Config) error {
+	config.ServerName = "localhost"
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLSExposesClientIdentity(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA := newTestCA(t, "Server CA"), newTestCA(t, "Client CA")
+	cert, key := serverCA.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	writeTestFile(t, certFile, cert)
+	writeTestFile(t, keyFile, key)
+	writeTestFile(t, caFile, clientCA.pem)
+	certs, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("failed to load TLS files: %v", err)
+	}
+	identities := make(chan ClientIdentity, 1)
+	address := serve(t, certs, identities)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.cert)
+	if err := check(address, &tls.Config{RootCAs: roots}); err == nil {
+		t.Fatalf("expected a client without a certificate to be refused")
+	}
+
+	strangerCA := newTestCA(t, "Stranger CA")
+	strangerCert, strangerKey := strangerCA.issue(t, "stranger", x509.ExtKeyUsageClientAuth)
+	stranger, _ := tls.X509KeyPair(strangerCert, strangerKey)
+	if err := check(address, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{stranger}}); err == nil {
+		t.Fatalf("expected a client with a certificate of another CA to be refused")
+	}
+
+	clientCert, clientKey := clientCA.issue(t, "reporting-job", x509.ExtKeyUsageClientAuth)
+	client, _ := tls.X509KeyPair(clientCert, clientKey)
+	if err := check(address, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client}}); err != nil {
+		t.Fatalf("expected the client certificate to be accepted, got %v", err)
+	}
+	id := <-identities
+	if id.Name() != "reporting-job" || id.Issuer != "Client CA" || len(id.DNSNames) != 1 {
+		t.Fatalf("unexpected client identity %+v", id)
+	}
+}
+
+func TestCertReloaderPicksUpChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newTestCA(t, "Old CA"), newTestCA(t, "New CA")
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert, key := oldCA.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	writeTestFile(t, certFile, cert)
+	writeTestFile(t, keyFile, key)
+	certs, err := NewCertReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("failed to load TLS files: %v", err)
+	}
+	certs.checkInterval = 0
+	reloads := make(chan error, 10)
+	certs.OnReload = func(err error) { reloads <- err }
+	address := serve(t, certs, make(chan ClientIdentity, 10))
+
+	oldRoots, newRoots := x509.NewCertPool(), x509.NewCertPool()
+	oldRoots.AddCert(oldCA.cert)
+	newRoots.AddCert(newCA.cert)
+	if err := check(address, &tls.Config{RootCAs: oldRoots}); err != nil {
+		t.Fatalf("expected the original certificate to be served, got %v", err)
+	}
+
+	// A certificate without its key is kept out of use.
+	cert, key = newCA.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	writeTestFile(t, certFile, cert)
+	if err := check(address, &tls.Config{RootCAs: oldRoots}); err != nil {
+		t.Fatalf("expected the original certificate to be served until the key is written, got %v", err)
+	}
+	if err := <-reloads; err == nil {
+		t.Fatalf("expected the mismatched key to fail the reload")
+	}
+
+	writeTestFile(t, keyFile, key)
+	if err := check(address, &tls.Config{RootCAs: newRoots}); err != nil {
+		t.Fatalf("expected the new certificate to be served, got %v", err)
+	}
+	if err := <-reloads; err != nil {
+		t.Fatalf("expected the reload to succeed, got %v", err)
+	}
+}
+
+func TestNewCertReloaderRejectsBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "CA")
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	cert, key := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	writeTestFile(t, certFile, cert)
+	writeTestFile(t, keyFile, key)
+	writeTestFile(t, caFile, []byte("not a certificate"))
+	if _, err := NewCertReloader(certFile, keyFile, caFile); err == nil {
+		t.Fatalf("expected an error for a CA bundle without certificates")
+	}
+	if _, err := NewCertReloader(certFile, filepath.Join(dir, "missing.pem"), ""); err == nil {
+		t.Fatalf("expected an error for a missing key file")
+	}
+}